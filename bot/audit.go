@@ -0,0 +1,349 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Action is a change to an entry, the ones in journal.go can also be undone
+type Action string
+
+const (
+	// Enumerations for actions
+	ACTION_ADD      Action = "add"
+	ACTION_DELETE   Action = "delete"
+	ACTION_RESTORE  Action = "restore"
+	ACTION_PURGE    Action = "purge"
+	ACTION_DONE     Action = "done"
+	ACTION_RATE     Action = "rate"
+	ACTION_UPDATE   Action = "update"
+	ACTION_RUNTIME  Action = "runtime"
+	ACTION_MOVE     Action = "move"
+	ACTION_TAG      Action = "tag"
+	ACTION_UNTAG    Action = "untag"
+	ACTION_LINK     Action = "link"
+	ACTION_UNLINK   Action = "unlink"
+	ACTION_STREAM   Action = "stream"
+	ACTION_UNSTREAM Action = "unstream"
+	ACTION_LOOKUP   Action = "lookup"
+	ACTION_UNDO     Action = "undo"
+
+	// Most changes shown by history and audit at once
+	HISTORY_LIMIT = 10
+)
+
+// Source is where a change came from
+type Source string
+
+const (
+	// Enumerations for sources
+	SOURCE_DISCORD Source = "discord"
+	SOURCE_CLI     Source = "cli"
+	SOURCE_API     Source = "api"
+	SOURCE_BOT     Source = "bot" // scheduled jobs, ex. purging the trash
+)
+
+// Actor is whoever makes a change
+type Actor struct {
+	UserID    string `json:"user_id,omitempty"` // empty for the bot itself, the cli and the admin API token
+	Source    Source `json:"source"`
+	GuildID   string `json:"guild_id,omitempty"`   // empty for DMs, the cli and the API
+	ChannelID string `json:"channel_id,omitempty"` // empty outside of discord
+}
+
+// The bot itself, for changes made by scheduled jobs
+var BOT_ACTOR = &Actor{Source: SOURCE_BOT}
+
+// Change is a row of the audit log
+type Change struct {
+	ChangeID int64     `json:"change_id"`
+	Date     time.Time `json:"date"`
+	Actor    Actor     `json:"actor"`
+	UserID   string    `json:"user_id"`
+	Title    string    `json:"title"`
+	Category Category  `json:"category"`
+	Year     int       `json:"year,omitempty"`
+	Action   Action    `json:"action"`
+	Before   *Entry    `json:"before,omitempty"` // nil for adds
+	After    *Entry    `json:"after,omitempty"`  // nil for purges
+}
+
+// AuditQuery filters the audit log of a guild
+type AuditQuery struct {
+	GuildID string
+	UserID  string    // changes made by or to this user, empty for everyone
+	From    time.Time // zero for no lower bound
+	To      time.Time // zero for no upper bound
+}
+
+// Common interface of *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Common interface of *sql.DB and *sql.Tx for reads
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Columns selected when loading changes, in the order queryChanges expects them
+const CHANGE_COLUMNS = "changeID, date, actorID, source, guildID, channelID, userID, title, category, year, action, before, after"
+
+/*
+Record a change to an entry in the audit log
+
+The entry is read back within the transaction for its state after the change.
+
+Params:
+
+	tx:			transaction the change is made in
+	actor:		who made the change
+	action:		what the change was
+	before:		the entry before the change, with its tags, links and availability
+*/
+func recordChange(tx *sql.Tx, actor *Actor, action Action, before *Entry) error {
+	after, err := reloadEntry(tx, before)
+	if err != nil {
+		return err
+	}
+	return writeChange(tx, actor, action, before, after)
+}
+
+// Insert a change into the audit log, either side can be nil but not both
+func writeChange(db execer, actor *Actor, action Action, before *Entry, after *Entry) error {
+	key := after
+	if key == nil {
+		key = before
+	}
+
+	snapshot := func(e *Entry) (any, error) {
+		if e == nil {
+			return nil, nil
+		}
+		data, err := json.Marshal(e)
+		return string(data), err
+	}
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := "INSERT INTO audit(date, actorID, source, guildID, channelID, userID, title, category, year, action, before, after) " +
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	args := append([]any{time.Now().UTC(), actor.UserID, actor.Source, actor.GuildID, actor.ChannelID}, key.key()...)
+	_, err = db.Exec(query, append(args, action, beforeJSON, afterJSON)...)
+	return err
+}
+
+// Load the current state of an entry by its exact key, trashed or not, nil if it no longer exists
+func reloadEntry(db querier, e *Entry) (*Entry, error) {
+	entries, err := queryEntries(db, "SELECT "+ENTRY_COLUMNS+" FROM entries WHERE "+ENTRY_KEY, e.key()...)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], (&Watchlist{Entries: entries}).loadDetails(db)
+}
+
+/*
+Fetch the changes made to a user's entries with a title, even if they've since been deleted
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		owner of the entries
+	title:		title of the entries
+	category:	category of the entries (empty string matches any category)
+	year:		release year of the entries (0 matches any year)
+
+Returns:
+
+	[]*Change:	up to HISTORY_LIMIT changes, newest first
+	error:		error object
+*/
+func FetchHistory(db *sql.DB, userID string, title string, category Category, year int) ([]*Change, error) {
+	query := "SELECT " + CHANGE_COLUMNS + " FROM audit WHERE userID = ? AND title = ? AND (? = '' OR category = ?) AND (? = 0 OR year = ?) " +
+		"ORDER BY changeID DESC LIMIT ?"
+	return queryChanges(db, query, userID, title, category, category, year, year, HISTORY_LIMIT)
+}
+
+/*
+Fetch the changes made from a guild
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	q:		guild, user and date range to fetch changes for
+
+Returns:
+
+	[]*Change:	up to HISTORY_LIMIT changes, newest first
+	error:		error object
+*/
+func FetchAudit(db *sql.DB, q *AuditQuery) ([]*Change, error) {
+	query := "SELECT " + CHANGE_COLUMNS + " FROM audit WHERE guildID = ?"
+	args := []any{q.GuildID}
+
+	if q.UserID != "" {
+		query += " AND (actorID = ? OR userID = ?)"
+		args = append(args, q.UserID, q.UserID)
+	}
+	if !q.From.IsZero() {
+		query += " AND date >= ?"
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		query += " AND date < ?"
+		args = append(args, q.To.UTC())
+	}
+
+	query += " ORDER BY changeID DESC LIMIT ?"
+	changes, err := queryChanges(db, query, append(args, HISTORY_LIMIT)...)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("audit.FetchAudit", "guild", q.GuildID, "user", q.UserID, "changes", len(changes))
+	return changes, nil
+}
+
+// Run a query selecting CHANGE_COLUMNS and create Change objects for each row
+func queryChanges(db *sql.DB, query string, args ...any) ([]*Change, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*Change
+	for rows.Next() {
+		var (
+			c             Change
+			before, after sql.NullString
+		)
+		err := rows.Scan(&c.ChangeID, &c.Date, &c.Actor.UserID, &c.Actor.Source, &c.Actor.GuildID, &c.Actor.ChannelID,
+			&c.UserID, &c.Title, &c.Category, &c.Year, &c.Action, &before, &after)
+		if err != nil {
+			return nil, err
+		}
+
+		if before.Valid {
+			if err := json.Unmarshal([]byte(before.String), &c.Before); err != nil {
+				return nil, err
+			}
+		}
+		if after.Valid {
+			if err := json.Unmarshal([]byte(after.String), &c.After); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, &c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Describe what a change did to its entry, ex. "rating 3 -> 8, +tag:classic"
+func (c *Change) Summary() string {
+	switch {
+	case c.Before == nil && c.After == nil:
+		return "no change"
+	case c.Before == nil:
+		return fmt.Sprintf("added at #%d", c.After.Priority)
+	case c.After == nil:
+		return "purged from the trash"
+	}
+
+	b, a := c.Before, c.After
+	var changes []string
+	switch {
+	case b.Deleted == nil && a.Deleted != nil:
+		changes = append(changes, "moved to the trash")
+	case b.Deleted != nil && a.Deleted == nil:
+		changes = append(changes, fmt.Sprintf("restored at #%d", a.Priority))
+	case b.Priority != a.Priority:
+		changes = append(changes, fmt.Sprintf("#%d -> #%d", b.Priority, a.Priority))
+	}
+	if b.Done != a.Done && a.Done {
+		changes = append(changes, "done")
+	} else if b.Done != a.Done {
+		changes = append(changes, "not done")
+	}
+	if b.Rating != a.Rating {
+		changes = append(changes, fmt.Sprintf("rating %d -> %d", b.Rating, a.Rating))
+	}
+	if b.Runtime != a.Runtime {
+		changes = append(changes, fmt.Sprintf("runtime %d -> %d min", b.Runtime, a.Runtime))
+	}
+	if len(b.Watches) != len(a.Watches) {
+		changes = append(changes, fmt.Sprintf("watches %d -> %d", len(b.Watches), len(a.Watches)))
+	}
+
+	links := func(e *Entry) []string {
+		urls := make([]string, len(e.Links))
+		for i, l := range e.Links {
+			urls[i] = fmt.Sprintf("%s (%s)", l.URL, l.Kind)
+		}
+		return urls
+	}
+	availability := func(e *Entry) []string {
+		services := make([]string, len(e.Availability))
+		for i, av := range e.Availability {
+			services[i] = av.String()
+		}
+		return services
+	}
+	changes = append(changes, diffValues("tag:", b.Tags, a.Tags)...)
+	changes = append(changes, diffValues("link ", links(b), links(a))...)
+	changes = append(changes, diffValues("on ", availability(b), availability(a))...)
+
+	if len(changes) == 0 {
+		return "no change"
+	}
+	return strings.Join(changes, ", ")
+}
+
+// List values only in after with a "+" and values only in before with a "-"
+func diffValues(prefix string, before []string, after []string) []string {
+	var diff []string
+	for _, v := range after {
+		if !slices.Contains(before, v) {
+			diff = append(diff, "+"+prefix+v)
+		}
+	}
+	for _, v := range before {
+		if !slices.Contains(after, v) {
+			diff = append(diff, "-"+prefix+v)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,435 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Availability is a streaming service an entry can be watched on
+type Availability struct {
+	Service  string `json:"service"`          // normalized service name, ex. "netflix"
+	Region   string `json:"region,omitempty"` // ISO 3166 country code, ex. "US", empty if unknown
+	Offer    Offer  `json:"offer"`
+	Imported bool   `json:"imported"` // from an availability provider rather than set by the user
+}
+
+// Offer is how a service offers a title
+type Offer string
+
+const (
+	// Enumerations for availability offers
+	OFFER_FREE Offer = "free" // included with a subscription, or free with ads
+	OFFER_RENT Offer = "rent"
+	OFFER_BUY  Offer = "buy"
+
+	// Region looked up when the user doesn't give one
+	DEFAULT_REGION = "US"
+)
+
+// Other names people use for services, after normalizeService removes spaces and punctuation
+var SERVICE_ALIASES = map[string]string{
+	"amazon":      "prime",
+	"amazonprime": "prime",
+	"primevideo":  "prime",
+	"disneyplus":  "disney",
+	"hbo":         "max",
+	"hbomax":      "max",
+	"appletv":     "apple",
+	"appletvplus": "apple",
+	"itunes":      "apple",
+}
+
+// AvailabilityProvider looks up where titles can be watched
+type AvailabilityProvider interface {
+	// Where an entry can be watched in a region, MetadataNotFoundError if the provider doesn't know the entry
+	Availability(e *Entry, region string) ([]*Availability, error)
+}
+
+// Provider used by the stream lookup command, nil if lookups are disabled
+var AVAILABILITY_PROVIDER AvailabilityProvider
+
+/*
+Normalize a service name: lowercase, without spaces or punctuation, with aliases resolved
+
+Example:
+
+	normalizeService("Disney+") == "disney"
+	normalizeService("Prime Video") == "prime"
+*/
+func normalizeService(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	service := b.String()
+	if alias, ok := SERVICE_ALIASES[service]; ok {
+		return alias
+	}
+	return service
+}
+
+/*
+Parse a service filter, ex. "netflix" or "netflix:ca"
+
+Returns:
+
+	service:	normalized service name
+	region:		uppercase region, empty to match any region
+*/
+func ParseServiceFilter(value string) (service string, region string) {
+	service, region, _ = strings.Cut(value, ":")
+	return normalizeService(service), strings.ToUpper(region)
+}
+
+// Normalize the service and region and check the availability makes sense
+func (a *Availability) IsValid() error {
+	a.Service = normalizeService(a.Service)
+	a.Region = strings.ToUpper(a.Region)
+	if a.Offer == "" {
+		a.Offer = OFFER_FREE
+	}
+
+	if a.Service == "" {
+		return &InvalidServiceError{a.Service}
+	}
+	if a.Region != "" && (len(a.Region) != 2 || strings.IndexFunc(a.Region, func(r rune) bool { return r < 'A' || r > 'Z' }) != -1) {
+		return &InvalidRegionError{a.Region}
+	}
+	return a.Offer.IsValid()
+}
+
+// Stringer for availability struct, ex. "netflix (US, free)"
+func (a *Availability) String() string {
+	if a.Region == "" {
+		return fmt.Sprintf("%s (%s)", a.Service, a.Offer)
+	}
+	return fmt.Sprintf("%s (%s, %s)", a.Service, a.Region, a.Offer)
+}
+
+// Check if an entry can be watched on a service, an empty region matches any region
+//
+// Availability without a region matches every region, users often don't say
+func (e *Entry) AvailableOn(service string, region string) bool {
+	service = normalizeService(service)
+	for _, a := range e.Availability {
+		if a.Service == service && (region == "" || a.Region == "" || strings.EqualFold(a.Region, region)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keep only the entries that can be watched on a service, see Entry.AvailableOn
+func (w *Watchlist) FilterService(service string, region string) {
+	var entries []*Entry
+	for _, e := range w.Entries {
+		if e.AvailableOn(service, region) {
+			entries = append(entries, e)
+		}
+	}
+	w.Entries = entries
+}
+
+/*
+Note where an entry can be watched
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	a:			service, region and offer, normalized before it's saved
+
+Returns:
+
+	*Entry:	ptr to the entry, with its availability
+	error:	error object
+*/
+func SetAvailability(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, a *Availability) (*Entry, error) {
+	if err := a.IsValid(); err != nil {
+		return nil, err
+	}
+
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	// Setting it by hand overrides an imported row for the same service, region and offer
+	a.Imported = false
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT OR REPLACE INTO availability(userID, title, category, year, service, region, offer, imported) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+	if _, err = tx.Exec(query, append(entry.key(), a.Service, a.Region, a.Offer, a.Imported)...); err != nil {
+		return nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_STREAM, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("availability.SetAvailability", "user", userID, "title", entry.Title, "category", entry.Category, "availability", a)
+	return entry, (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db)
+}
+
+/*
+Remove a service from where an entry can be watched
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	service:	service to remove
+	region:		region to remove it from, empty for every region
+
+Returns:
+
+	*Entry:	ptr to the entry, with its remaining availability
+	error:	AvailabilityNotFoundError if the entry wasn't on the service
+*/
+func RemoveAvailability(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, service string, region string) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	service, region = normalizeService(service), strings.ToUpper(region)
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "DELETE FROM availability WHERE " + ENTRY_KEY + " AND service = ? AND (? = '' OR region = ?)"
+	result, err := tx.Exec(query, append(entry.key(), service, region, region)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, &AvailabilityNotFoundError{entry.DisplayTitle(), service}
+	}
+	if err = recordChange(tx, actor, ACTION_UNSTREAM, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("availability.RemoveAvailability", "user", userID, "title", entry.Title, "category", entry.Category, "service", service, "region", region)
+	return entry, (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db)
+}
+
+/*
+Look up where an entry can be watched in a region and save it
+
+Replaces what was imported for the region before, availability set by the user is kept.
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	provider:	provider to look the entry up in
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	region:		region to look up
+
+Returns:
+
+	*Entry:				ptr to the entry, with its availability
+	[]*Availability:	what the provider found
+	error:				NoAvailabilityProviderError, MetadataNotFoundError or a database error
+*/
+func ImportAvailability(db *sql.DB, actor *Actor, provider AvailabilityProvider, userID string, title string, category Category, year int, region string) (*Entry, []*Availability, error) {
+	if provider == nil {
+		return nil, nil, &NoAvailabilityProviderError{}
+	}
+
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	region = strings.ToUpper(region)
+	found, err := provider.Availability(entry, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM availability WHERE "+ENTRY_KEY+" AND imported = 1 AND region = ?", append(entry.key(), region)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, a := range found {
+		a.Region, a.Imported = region, true
+		if err := a.IsValid(); err != nil {
+			return nil, nil, err
+		}
+
+		query := "INSERT OR IGNORE INTO availability(userID, title, category, year, service, region, offer, imported) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+		if _, err = tx.Exec(query, append(entry.key(), a.Service, a.Region, a.Offer, a.Imported)...); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err = recordChange(tx, actor, ACTION_LOOKUP, entry); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	slog.Debug("availability.ImportAvailability", "user", userID, "title", entry.Title, "category", entry.Category, "region", region, "found", len(found))
+	return entry, found, (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db)
+}
+
+/*
+Attach availability to the entries of a watchlist
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	error:	error object
+*/
+func (w *Watchlist) loadAvailability(db querier) error {
+	// Same indexing as loadTags
+	index := make(map[entryKey]*Entry, len(w.Entries))
+	users := make(map[string]bool)
+	for _, e := range w.Entries {
+		e.Availability = nil
+		index[e.mapKey()] = e
+		users[e.UserID] = true
+	}
+
+	for userID := range users {
+		query := "SELECT title, category, year, service, region, offer, imported FROM availability WHERE userID = ? ORDER BY service, region, offer"
+		rows, err := db.Query(query, userID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var (
+				available = Entry{UserID: userID}
+				a         Availability
+			)
+			if err := rows.Scan(&available.Title, &available.Category, &available.Year, &a.Service, &a.Region, &a.Offer, &a.Imported); err != nil {
+				rows.Close()
+				return err
+			}
+
+			if e, ok := index[available.mapKey()]; ok {
+				e.Availability = append(e.Availability, &a)
+			}
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+Where a fixture title can be watched in a region
+
+Entries are matched by their external ID, or by title the same way Entry.Enrich matches them.
+
+Params:
+
+	e:		entry to look up
+	region:	region to look up
+
+Returns:
+
+	[]*Availability:	the title's availability in the region
+	error:				MetadataNotFoundError if the dataset doesn't have the title
+*/
+func (p *FixtureProvider) Availability(e *Entry, region string) ([]*Availability, error) {
+	m, ok := p.byID[e.ExternalID]
+	if !ok {
+		lookup := &Entry{Title: e.Title, Category: e.Category, Year: e.Year}
+		found, err := lookup.Enrich(p)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			return nil, &MetadataNotFoundError{e.DisplayTitle()}
+		}
+		m = found
+	}
+
+	var available []*Availability
+	for _, a := range m.Availability {
+		if strings.EqualFold(a.Region, region) {
+			available = append(available, &Availability{Service: a.Service, Region: a.Region, Offer: a.Offer})
+		}
+	}
+	return available, nil
+}
+
+// enum validation
+func (o *Offer) IsValid() error {
+	switch *o {
+	case OFFER_FREE, OFFER_RENT, OFFER_BUY:
+		return nil
+	default:
+		return &InvalidOfferError{o}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Number of titles shown in each section of a comparison
+const COMPARE_SIZE = 10
+
+// RatingPair is a title both users completed and rated
+type RatingPair struct {
+	Title       string   `json:"title"`
+	Category    Category `json:"category"`
+	Year        int      `json:"year,omitempty"`
+	Rating      int      `json:"rating"`
+	OtherRating int      `json:"other_rating"`
+}
+
+// Difference between the two ratings
+func (p *RatingPair) Difference() int {
+	if p.Rating > p.OtherRating {
+		return p.Rating - p.OtherRating
+	}
+	return p.OtherRating - p.Rating
+}
+
+// Comparison of two users' watchlists
+type Comparison struct {
+	BothUnwatched []*Entry      `json:"both_unwatched"` // the first user's entries
+	YouFinished   []*Entry      `json:"you_finished"`   // finished by the first user, queued by the other
+	TheyFinished  []*Entry      `json:"they_finished"`  // finished by the other user, queued by the first
+	Disagreements []*RatingPair `json:"disagreements"`  // biggest difference first
+}
+
+// Key that matches the same title across users, ignoring case
+func compareKey(e *Entry) string {
+	return fmt.Sprintf("%s:%s:%d", e.Category, strings.ToLower(e.Title), e.Year)
+}
+
+/*
+Compare two watchlists, matching titles case-insensitively within a category and release year
+
+Params:
+
+	yours:	the first watchlist, including watched items
+	theirs:	the other watchlist, including watched items
+
+Returns:
+
+	*Comparison:	ptr to the comparison
+*/
+func CompareWatchlists(yours *Watchlist, theirs *Watchlist) *Comparison {
+	comparison := &Comparison{}
+
+	other := make(map[string]*Entry, len(theirs.Entries))
+	for _, e := range theirs.Entries {
+		other[compareKey(e)] = e
+	}
+
+	for _, e := range yours.Entries {
+		o, ok := other[compareKey(e)]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case !e.Done && !o.Done:
+			comparison.BothUnwatched = append(comparison.BothUnwatched, e)
+		case e.Done && !o.Done:
+			comparison.YouFinished = append(comparison.YouFinished, e)
+		case !e.Done && o.Done:
+			comparison.TheyFinished = append(comparison.TheyFinished, o)
+		case e.Rating > 0 && o.Rating > 0 && e.Rating != o.Rating:
+			comparison.Disagreements = append(comparison.Disagreements, &RatingPair{
+				Title:       e.Title,
+				Year:        e.Year,
+				Category:    e.Category,
+				Rating:      e.Rating,
+				OtherRating: o.Rating,
+			})
+		}
+	}
+
+	sort.SliceStable(comparison.Disagreements, func(i, j int) bool {
+		return comparison.Disagreements[i].Difference() > comparison.Disagreements[j].Difference()
+	})
+
+	return comparison
+}
+
+/*
+Render the comparison as an embedded message
+
+Params:
+
+	you:	name of the first user
+	them:	name of the other user
+
+Returns:
+
+	*discordgo.MessageEmbed:	ptr to the embed
+*/
+func (c *Comparison) Embed(you string, them string) *discordgo.MessageEmbed {
+	block := func(lines []string) string {
+		if len(lines) == 0 {
+			return "-"
+		}
+		if len(lines) > COMPARE_SIZE {
+			lines = append(lines[:COMPARE_SIZE], fmt.Sprintf("...and %d more", len(lines)-COMPARE_SIZE))
+		}
+		return "```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+	titles := func(entries []*Entry) []string {
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			lines[i] = fmt.Sprintf("%s (%s)", e.DisplayTitle(), e.Category)
+		}
+		return lines
+	}
+
+	var disagreements []string
+	for _, p := range c.Disagreements {
+		disagreements = append(disagreements, fmt.Sprintf("%s (%s) - %d vs %d", displayTitle(p.Title, p.Year), p.Category, p.Rating, p.OtherRating))
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("%s vs %s", you, them),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Both unwatched", Value: block(titles(c.BothUnwatched))},
+			{Name: fmt.Sprintf("Finished by %s, queued by %s", you, them), Value: block(titles(c.YouFinished))},
+			{Name: fmt.Sprintf("Finished by %s, queued by %s", them, you), Value: block(titles(c.TheyFinished))},
+			{Name: "Rating disagreements", Value: block(disagreements)},
+		},
+	}
+}
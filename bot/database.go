@@ -0,0 +1,114 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Schema migrations, applied in filename order (001_init.sql, 002_..., etc.)
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+/*
+Open a sqlite3 database
+
+sqlite leaves foreign keys off unless every connection turns them on, so the
+connection string does it for each connection in the pool
+
+Params:
+
+	path:	path to the database file, created if it doesn't exist
+
+Returns:
+
+	*sql.DB:	ptr to sqlite3 database connection
+	error:		error object
+*/
+func OpenDatabase(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", path+"?_foreign_keys=on")
+}
+
+/*
+Bring the database schema up to date
+
+The number of applied migrations is tracked with sqlite's user_version pragma,
+so running this on every startup only applies migrations that are new
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	error:	error object
+*/
+func Migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	for i := version; i < len(files); i++ {
+		script, err := migrations.ReadFile("migrations/" + files[i].Name())
+		if err != nil {
+			return err
+		}
+
+		// Apply each migration in its own transaction so a failure leaves the schema untouched
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.Exec(string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", files[i].Name(), err)
+		}
+
+		// PRAGMA statements don't accept placeholders
+		if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+
+		slog.Info("database.Migrate", "migration", files[i].Name())
+	}
+
+	return nil
+}
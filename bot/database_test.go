@@ -0,0 +1,250 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Actor for changes made by tests
+var TEST_ACTOR = &Actor{Source: SOURCE_CLI}
+
+// Open an empty database in a temporary directory, migrated up to date
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Open an empty database in a temporary directory without migrating it
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "watchlist.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Add entries to the test database, added now and appended to the bottom of the queue
+func addTestEntries(t *testing.T, db *sql.DB, entries ...*Entry) {
+	t.Helper()
+
+	for _, e := range entries {
+		if e.Date.IsZero() {
+			e.Date = time.Now()
+		}
+		if err := e.Add(db, TEST_ACTOR); err != nil {
+			t.Fatalf("add %s: %v", e.Title, err)
+		}
+	}
+}
+
+// Apply only the first n migrations, the way Migrate would have on an older version of the bot
+func migrateTo(t *testing.T, db *sql.DB, n int) {
+	t.Helper()
+
+	files, err := migrations.ReadDir("migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+
+	for _, file := range files[:n] {
+		script, err := migrations.ReadFile("migrations/" + file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec(string(script)); err != nil {
+			t.Fatalf("%s: %v", file.Name(), err)
+		}
+	}
+	if _, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", n)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Migration 012 rebuilds entries and tags with the release year in their primary key
+func TestMigrateReleaseYearKey(t *testing.T) {
+	db := openTestDB(t)
+	migrateTo(t, db, 11)
+
+	// Rows written before release years existed
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := []struct {
+		userID   string
+		title    string
+		category Category
+		rating   int
+		tags     []string
+	}{
+		{"1", "Dune", Movie, 8, []string{"sci-fi", "remake"}},
+		{"1", "Dune", Show, 0, nil},
+		{"1", "The Godfather", Movie, 10, []string{"crime"}},
+		{"2", "Dune", Movie, 0, []string{"sci-fi"}},
+	}
+	for _, e := range old {
+		_, err := db.Exec("INSERT INTO entries(userID, date, title, category, done, rating, priority) VALUES(?, ?, ?, ?, ?, ?, ?)",
+			e.userID, date, e.title, e.category, e.rating > 0, e.rating, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range e.tags {
+			_, err = db.Exec("INSERT INTO tags(userID, title, category, tag) VALUES(?, ?, ?, ?)", e.userID, e.title, e.category, tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	_, err := db.Exec("INSERT INTO reminders(userID, channelID, dm, title, category, due, repeat) VALUES('1', 'c', 0, 'Dune', 'movie', ?, '')", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every entry keeps its details and tags, with an unknown year
+	for _, e := range old {
+		entry, err := FindEntry(db, ADMIN_VIEWER, e.userID, e.title, e.category, 0)
+		if err != nil {
+			t.Fatalf("%s %s (%s): %v", e.userID, e.title, e.category, err)
+		}
+		if entry.Year != 0 || entry.Rating != e.rating || !entry.Date.Equal(date) {
+			t.Errorf("%s %s (%s): got year %d rating %d date %v", e.userID, e.title, e.category, entry.Year, entry.Rating, entry.Date)
+		}
+
+		watchlist := &Watchlist{Entries: []*Entry{entry}}
+		if err = watchlist.loadTags(db); err != nil {
+			t.Fatal(err)
+		}
+		if len(entry.Tags) != len(e.tags) {
+			t.Errorf("%s %s (%s): got tags %v, want %v", e.userID, e.title, e.category, entry.Tags, e.tags)
+		}
+	}
+
+	// The year is part of the key now, so remakes can sit next to each other but not twice
+	keys := []struct {
+		year    int
+		wantErr bool
+	}{
+		{2021, false},
+		{1984, false},
+		{2021, true},
+		{0, true},
+	}
+	for _, k := range keys {
+		_, err := db.Exec("INSERT INTO entries(userID, date, title, category, year, done) VALUES('1', ?, 'Dune', 'movie', ?, 0)", date, k.year)
+		if (err != nil) != k.wantErr {
+			t.Errorf("insert Dune (%d): got error %v, want error %v", k.year, err, k.wantErr)
+		}
+	}
+
+	// Tags and reminders still go with their entry, and only that entry
+	if _, err = db.Exec("DELETE FROM entries WHERE userID = '1' AND title = 'Dune' AND category = 'movie' AND year = 0"); err != nil {
+		t.Fatal(err)
+	}
+	counts := []struct {
+		query string
+		want  int
+	}{
+		{"SELECT COUNT(*) FROM tags WHERE userID = '1' AND title = 'Dune'", 0},
+		{"SELECT COUNT(*) FROM tags WHERE userID = '2' AND title = 'Dune'", 1},
+		{"SELECT COUNT(*) FROM reminders WHERE userID = '1'", 0},
+		{"SELECT COUNT(*) FROM entries WHERE userID = '1' AND title = 'Dune'", 3},
+	}
+	for _, c := range counts {
+		var got int
+		if err = db.QueryRow(c.query).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %d, want %d", c.query, got, c.want)
+		}
+	}
+}
+
+// Migration 022 closes the gaps deletes used to leave in queues
+func TestMigrateQueuePositions(t *testing.T) {
+	db := openTestDB(t)
+	migrateTo(t, db, 21)
+
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	old := []struct {
+		userID   string
+		title    string
+		priority int
+		deleted  any
+	}{
+		{"1", "Alien", 2, nil},
+		{"1", "Dune", 5, nil},
+		{"1", "Heat", 3, date},
+		{"1", "Jaws", 9, nil},
+		{"2", "Alien", 4, nil},
+	}
+	for _, e := range old {
+		_, err := db.Exec("INSERT INTO entries(userID, date, title, category, done, priority, deleted) VALUES(?, ?, ?, 'movie', 0, ?, ?)",
+			e.userID, date, e.title, e.priority, e.deleted)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"1 Alien": 1, "1 Dune": 2, "1 Heat": 3, "1 Jaws": 3, "2 Alien": 1}
+	rows, err := db.Query("SELECT userID, title, priority FROM entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			userID, title string
+			priority      int
+		)
+		if err := rows.Scan(&userID, &title, &priority); err != nil {
+			t.Fatal(err)
+		}
+		if key := userID + " " + title; priority != want[key] {
+			t.Errorf("%s: got priority %d, want %d", key, priority, want[key])
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// Prefix of the custom IDs of duplicate prompt buttons, ex. dupe:12:keep
+	DUPLICATE_BUTTON_PREFIX = "dupe"
+
+	DUPLICATE_KEEP   = "keep"   // Add the new entry next to the existing one
+	DUPLICATE_LINK   = "link"   // Put the new entry's link on the existing one instead
+	DUPLICATE_CANCEL = "cancel" // Don't add anything
+
+	// How long a duplicate prompt can be answered
+	PENDING_ADD_LIFETIME = 24 * time.Hour
+)
+
+// PendingAdd is an add waiting on the user to decide what to do about the entry it duplicates
+type PendingAdd struct {
+	PendingID int64     `json:"pending_id"`
+	UserID    string    `json:"user_id"`
+	Date      time.Time `json:"date"`
+	Entry     *Entry    `json:"entry"` // entry that was going to be added
+
+	// Key of the existing entry
+	Title    string   `json:"title"`
+	Category Category `json:"category"`
+	Year     int      `json:"year"`
+}
+
+// True if two entries are probably the same title: same user, same title ignoring case, punctuation
+// and a leading "the", and the same release year unless one of them is unknown
+func (e *Entry) isDuplicateOf(other *Entry) bool {
+	if e.UserID != other.UserID || normalizeTitle(e.Title) != normalizeTitle(other.Title) {
+		return false
+	}
+	return e.Year == 0 || other.Year == 0 || e.Year == other.Year
+}
+
+// True if two entries have the same key, so they can't both be on the watchlist
+func (e *Entry) sameKey(other *Entry) bool {
+	return e.UserID == other.UserID && e.Title == other.Title && e.Category == other.Category && e.Year == other.Year
+}
+
+/*
+Find the entries on a user's watchlist that an entry about to be added duplicates
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	e:		ptr to the entry about to be added
+
+Returns:
+
+	[]*Entry:	duplicates with their details, an exact match first, then by priority
+	error:		error object
+*/
+func FindDuplicates(db *sql.DB, e *Entry) ([]*Entry, error) {
+	query := "SELECT " + ENTRY_COLUMNS + " FROM entries WHERE userID = ? AND " + NOT_DELETED + " ORDER BY priority"
+	entries, err := queryEntries(db, query, e.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []*Entry
+	for _, other := range entries {
+		switch {
+		case e.sameKey(other):
+			duplicates = append([]*Entry{other}, duplicates...)
+		case e.isDuplicateOf(other):
+			duplicates = append(duplicates, other)
+		}
+	}
+	if err = (&Watchlist{Entries: duplicates}).loadDetails(db); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("duplicates.FindDuplicates", "user", e.UserID, "title", e.Title, "duplicates", len(duplicates))
+	return duplicates, nil
+}
+
+/*
+Save an add until the user decides what to do about the entry it duplicates
+
+Also prunes the user's prompts that are too old to answer.
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	e:			ptr to the entry that was going to be added
+	existing:	ptr to the entry it duplicates
+
+Returns:
+
+	*PendingAdd:	ptr to the saved add
+	error:			error object
+*/
+func SavePendingAdd(db *sql.DB, e *Entry, existing *Entry) (*PendingAdd, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PendingAdd{UserID: e.UserID, Date: time.Now().UTC(), Entry: e, Title: existing.Title, Category: existing.Category, Year: existing.Year}
+	query := "INSERT INTO pending_adds(userID, date, entry, title, category, year) VALUES(?, ?, ?, ?, ?, ?)"
+	result, err := db.Exec(query, p.UserID, p.Date, string(data), p.Title, p.Category, p.Year)
+	if err != nil {
+		return nil, err
+	}
+	if p.PendingID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM pending_adds WHERE userID = ? AND date < ?", p.UserID, p.Date.Add(-PENDING_ADD_LIFETIME))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("duplicates.SavePendingAdd", "pending", p.PendingID, "user", p.UserID, "title", e.Title)
+	return p, nil
+}
+
+/*
+Fetch a user's pending add
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	pendingID:	ID of the pending add
+	userID:		user ID that made the add, nobody else can answer its prompt
+
+Returns:
+
+	*PendingAdd:	ptr to the pending add
+	error:			PendingAddNotFoundError if it doesn't exist, expired or isn't the user's
+*/
+func FetchPendingAdd(db *sql.DB, pendingID int64, userID string) (*PendingAdd, error) {
+	var (
+		p    = &PendingAdd{PendingID: pendingID, UserID: userID}
+		data string
+	)
+	query := "SELECT date, entry, title, category, year FROM pending_adds WHERE pendingID = ? AND userID = ? AND date >= ?"
+	err := db.QueryRow(query, pendingID, userID, time.Now().UTC().Add(-PENDING_ADD_LIFETIME)).Scan(&p.Date, &data, &p.Title, &p.Category, &p.Year)
+	if err == sql.ErrNoRows {
+		return nil, &PendingAddNotFoundError{pendingID}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(data), &p.Entry); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Remove a pending add once its prompt is answered
+func (p *PendingAdd) Delete(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM pending_adds WHERE pendingID = ?", p.PendingID)
+	return err
+}
+
+// Keep both, update link and cancel buttons shown under a duplicate prompt
+func (p *PendingAdd) Components() []discordgo.MessageComponent {
+	existing := &Entry{UserID: p.UserID, Title: p.Title, Category: p.Category, Year: p.Year}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			// An exact duplicate can't be added, and there's no link to move over without one
+			discordgo.Button{Label: "Keep both", Style: discordgo.PrimaryButton, CustomID: p.buttonID(DUPLICATE_KEEP), Disabled: p.Entry.sameKey(existing)},
+			discordgo.Button{Label: "Update link", Style: discordgo.SecondaryButton, CustomID: p.buttonID(DUPLICATE_LINK), Disabled: p.Entry.Link == ""},
+			discordgo.Button{Label: "Cancel", Style: discordgo.DangerButton, CustomID: p.buttonID(DUPLICATE_CANCEL)},
+		}},
+	}
+}
+
+// Custom ID of a duplicate prompt button, ex. dupe:12:keep
+func (p *PendingAdd) buttonID(action string) string {
+	return fmt.Sprintf("%s:%d:%s", DUPLICATE_BUTTON_PREFIX, p.PendingID, action)
+}
@@ -0,0 +1,110 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Dune", Category: Movie, Year: 2021},
+		&Entry{UserID: "1", Title: "Dune", Category: Movie, Year: 1984},
+		&Entry{UserID: "1", Title: "Dune", Category: Show},
+		&Entry{UserID: "1", Title: "The Godfather", Category: Movie},
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "2", Title: "Alien", Category: Movie},
+	)
+	if err := DeleteEntry(db, TEST_ACTOR, "1", "Alien", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		entry Entry
+		want  []string // display titles and categories, in order
+	}{
+		{"exact first", Entry{Title: "Dune", Category: Show}, []string{"Dune (show)", "Dune (2021) (movie)", "Dune (1984) (movie)"}},
+		{"year picks one remake", Entry{Title: "dune", Category: Movie, Year: 1984}, []string{"Dune (1984) (movie)", "Dune (show)"}},
+		{"unknown year matches every remake", Entry{Title: "DUNE", Category: Anime}, []string{"Dune (2021) (movie)", "Dune (1984) (movie)", "Dune (show)"}},
+		{"normalized title", Entry{Title: "godfather", Category: Movie}, []string{"The Godfather (movie)"}},
+		{"other year", Entry{Title: "The Godfather", Category: Movie, Year: 1972}, []string{"The Godfather (movie)"}},
+		{"trashed entries aren't duplicates", Entry{Title: "Alien", Category: Movie}, nil},
+		{"other users", Entry{UserID: "2", Title: "Dune", Category: Movie}, nil},
+		{"no duplicates", Entry{Title: "Heat", Category: Movie}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tt.entry
+			if e.UserID == "" {
+				e.UserID = "1"
+			}
+
+			duplicates, err := FindDuplicates(db, &e)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, d := range duplicates {
+				got = append(got, d.DisplayTitle()+" ("+string(d.Category)+")")
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPendingAdd(t *testing.T) {
+	db := newTestDB(t)
+	existing := &Entry{UserID: "1", Title: "Dune", Category: Movie, Year: 2021}
+	addTestEntries(t, db, existing)
+
+	e := &Entry{UserID: "1", Title: "Dune", Category: Movie, Link: "https://www.imdb.com/title/tt0087182/"}
+	saved, err := SavePendingAdd(db, e, existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the user that made the add can answer its prompt
+	var notFound *PendingAddNotFoundError
+	if _, err = FetchPendingAdd(db, saved.PendingID, "2"); !errors.As(err, &notFound) {
+		t.Errorf("fetch as another user = %v, want PendingAddNotFoundError", err)
+	}
+
+	p, err := FetchPendingAdd(db, saved.PendingID, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Entry.Title != "Dune" || p.Entry.Link != e.Link || p.Year != 2021 {
+		t.Errorf("got pending add of %+v for Dune (%d)", p.Entry, p.Year)
+	}
+
+	if err = p.Delete(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = FetchPendingAdd(db, saved.PendingID, "1"); !errors.As(err, &notFound) {
+		t.Errorf("fetch after delete = %v, want PendingAddNotFoundError", err)
+	}
+}
@@ -23,20 +23,39 @@ import (
 	"fmt"
 	"log/slog"
 	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Entry represents a single entry in the watchlist
 type Entry struct {
-	UserID   string    `json:"user_id"`
-	Date     time.Time `json:"date"`
-	Title    string    `json:"title"`
-	Category Category  `json:"category"`
-	Done     bool      `json:"done"`
-	Rating   int       `json:"rating"`
-	Link     string    `json:"link"`
+	UserID     string     `json:"user_id"`
+	Date       time.Time  `json:"date"`
+	Title      string     `json:"title"`
+	Category   Category   `json:"category"`
+	Year       int        `json:"year,omitempty"`        // release year, 0 if unknown
+	ExternalID string     `json:"external_id,omitempty"` // ex. imdb:tt1160419, empty if unknown
+	Done       bool       `json:"done"`
+	Rating     int        `json:"rating"`
+	Link       string     `json:"link"`            // most relevant of Links, empty if there are none
+	Links      []*Link    `json:"links,omitempty"` // most relevant first
+	Priority   int        `json:"priority"`
+	Runtime    int        `json:"runtime"`
+	Tags       []string   `json:"tags,omitempty"`
+	DoneDate   *time.Time `json:"done_date,omitempty"` // nil if not done, or done before dates were recorded
+	Deleted    *time.Time `json:"deleted,omitempty"`   // when it was moved to the trash, nil if it's on the watchlist
+	Genres     []string   `json:"genres,omitempty"`
+	Poster     string     `json:"poster,omitempty"`
+	Episodes   int        `json:"episodes,omitempty"`
+
+	Availability []*Availability `json:"availability,omitempty"` // where it's streaming, see availability.go
+	Watches      []*Watch        `json:"watches,omitempty"`      // every viewing, oldest first, see watches.go
 }
 
 // Category represents the type of item in the watchlist
@@ -48,152 +67,657 @@ const (
 	Anime Category = "anime"
 )
 
-// Adds an entry to the database
-func (e *Entry) Add(db *sql.DB) error {
-	// Prepare insert statement
-	query := "INSERT INTO entries(userID, date, title, category, done, rating, link) VALUES(?, ?, ?, ?, ?)"
-	statement, err := db.Prepare(query)
+/*
+Adds an entry to the database
+
+Entries with a priority of 0 are appended to the bottom of the user's queue,
+otherwise the entry is inserted at that position and everything below it moves down.
+An entry that's in the trash has to be restored instead of added again.
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	actor:	who is adding the entry
+
+Returns:
+
+	error:	error object
+*/
+func (e *Entry) Add(db *sql.DB, actor *Actor) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	defer statement.Close()
+	defer tx.Rollback()
 
-	// Execute insert statement
-	_, err = statement.Exec(e.UserID, e.Date, e.Title, e.Category, e.Done, e.Rating, e.Link)
+	// A trashed copy still holds the entry's key
+	var trashed bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM entries WHERE "+ENTRY_KEY+" AND deleted IS NOT NULL)", e.key()...).Scan(&trashed)
 	if err != nil {
 		return err
 	}
+	if trashed {
+		return &EntryInTrashError{e.DisplayTitle()}
+	}
+
+	if err = e.makeRoom(tx); err != nil {
+		return err
+	}
+
+	// Execute insert statements
+	if err = e.insert(tx); err != nil {
+		return err
+	}
+	if err = writeChange(tx, actor, ACTION_ADD, nil, e); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 
 	slog.Debug("entry.Add", "entry", e)
 	return nil
 }
 
-/*
-Delete an entry from the database
+// Clamp the entry's priority to the user's queue and move everything at or below it down one
+func (e *Entry) makeRoom(tx *sql.Tx) error {
+	// Find the bottom of the queue
+	var last int
+	err := tx.QueryRow("SELECT COALESCE(MAX(priority), 0) FROM entries WHERE userID = ? AND "+NOT_DELETED, e.UserID).Scan(&last)
+	if err != nil {
+		return err
+	}
 
-Params:
+	if e.Priority <= 0 || e.Priority > last {
+		e.Priority = last + 1
+		return nil
+	}
 
-	db:		ptr to sqlite3 database connection
-	userID:	user ID of the entry
-*/
-func DeleteEntry(db *sql.DB, userID string, title string, category Category) error {
-	// Prepare delete statement
-	statement, err := db.Prepare("DELETE FROM entries WHERE userID = ? and title = ? and category = ?")
+	_, err = tx.Exec("UPDATE entries SET priority = priority + 1 WHERE userID = ? AND priority >= ? AND "+NOT_DELETED, e.UserID, e.Priority)
+	return err
+}
+
+// Load a user's queue, top first
+func loadQueue(tx *sql.Tx, userID string) ([]*Entry, error) {
+	rows, err := tx.Query("SELECT title, category, year FROM entries WHERE userID = ? AND "+NOT_DELETED+" ORDER BY priority, date", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queue []*Entry
+	for rows.Next() {
+		e := &Entry{UserID: userID}
+		if err := rows.Scan(&e.Title, &e.Category, &e.Year); err != nil {
+			return nil, err
+		}
+		queue = append(queue, e)
+	}
+	return queue, rows.Err()
+}
+
+// Number a queue from 1 at the top so positions stay contiguous
+func writeQueue(tx *sql.Tx, queue []*Entry) error {
+	statement, err := tx.Prepare("UPDATE entries SET priority = ? WHERE " + ENTRY_KEY)
 	if err != nil {
 		return err
 	}
 	defer statement.Close()
 
-	// Execute delete statement
-	_, err = statement.Exec(userID, title, category)
+	for i, e := range queue {
+		if _, err = statement.Exec(append([]any{i + 1}, e.key()...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close the gap an entry leaves when it's taken out of a user's queue
+func renumberQueue(tx *sql.Tx, userID string) error {
+	queue, err := loadQueue(tx, userID)
 	if err != nil {
 		return err
 	}
+	return writeQueue(tx, queue)
+}
+
+// Insert an entry as it is, with its tags, links, availability and watch log
+func (e *Entry) insert(tx *sql.Tx) error {
+	var doneDate any
+	if e.DoneDate != nil {
+		doneDate = e.DoneDate.UTC()
+	}
+
+	query := "INSERT INTO entries(userID, date, title, category, year, externalID, done, rating, priority, runtime, doneDate, genres, poster, episodes) " +
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := tx.Exec(query, e.UserID, e.Date.UTC(), e.Title, e.Category, e.Year, e.ExternalID, e.Done, e.Rating, e.Priority,
+		e.Runtime, doneDate, strings.Join(e.Genres, ","), e.Poster, e.Episodes)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range e.Tags {
+		_, err = tx.Exec("INSERT OR IGNORE INTO tags(userID, title, category, year, tag) VALUES(?, ?, ?, ?, ?)", append(e.key(), tag)...)
+		if err != nil {
+			return err
+		}
+	}
+	for _, link := range e.Links {
+		_, err = tx.Exec("INSERT OR REPLACE INTO links(userID, title, category, year, url, kind) VALUES(?, ?, ?, ?, ?, ?)",
+			append(e.key(), link.URL, link.Kind)...)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range e.Availability {
+		query := "INSERT OR REPLACE INTO availability(userID, title, category, year, service, region, offer, imported) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+		if _, err = tx.Exec(query, append(e.key(), a.Service, a.Region, a.Offer, a.Imported)...); err != nil {
+			return err
+		}
+	}
+	for _, w := range e.Watches {
+		var date any
+		if w.Date != nil {
+			date = w.Date.UTC()
+		}
+
+		query := "INSERT OR REPLACE INTO watches(watchID, userID, title, category, year, date, rating, note) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+		if _, err = tx.Exec(query, append(append([]any{w.WatchID}, e.key()...), date, w.Rating, w.Note)...); err != nil {
+			return err
+		}
+	}
 
-	slog.Debug("entry.DeleteEntry", "user", userID, "title", title, "category", category)
 	return nil
 }
 
 /*
-Updates the link for an entry in the database
+Move an entry to the trash
+
+Trashed entries keep their details and can be restored until they're purged, see trash.go.
+Entries below it in the queue move up one.
 
 Params:
 
 	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
 	userID:		user ID of the entry
 	title:		title of the entry
-	category:	category of the entry
-	newLink:	new link to update the entry with
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
 
 Returns:
 
 	error:	error object
 */
-func UpdateEntry(db *sql.DB, userID string, title string, category Category, newLink string) error {
+func DeleteEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int) error {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return err
+	}
 
-	// Prepate update statement
-	query := "UPDATE entries SET link = ? WHERE userID = ? and title = ? and category = ?"
-	statement, err := db.Prepare(query)
+	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
-	defer statement.Close()
+	defer tx.Rollback()
 
-	// Execute update statement
-	_, err = statement.Exec(newLink, userID, title, category)
+	// Execute soft delete statement
+	_, err = tx.Exec("UPDATE entries SET deleted = ? WHERE "+ENTRY_KEY, append([]any{time.Now().UTC()}, entry.key()...)...)
 	if err != nil {
 		return err
 	}
+	if err = renumberQueue(tx, userID); err != nil {
+		return err
+	}
+	if err = recordAction(tx, ACTION_DELETE, entry); err != nil {
+		return err
+	}
+	if err = recordChange(tx, actor, ACTION_DELETE, entry); err != nil {
+		return err
+	}
 
-	slog.Debug("entry.UpdateEntry", "user", userID, "title", title, "category", category, "newLink", newLink)
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	slog.Debug("entry.DeleteEntry", "user", userID, "title", entry.Title, "category", entry.Category, "year", entry.Year)
 	return nil
 }
 
 /*
-Mark an entry as completed in the database
+Updates the link for an entry in the database
+
+The new link replaces the entry's links of the same kind, ex. a YouTube link replaces its trailer
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	newLink:	new link to update the entry with, canonicalized before it's saved
+
+Returns:
+
+	*Entry:	ptr to the updated entry, with its links
+	*Link:	ptr to the new link
+	error:	InvalidLinkError, EntryNotFoundError, AmbiguousEntryError or a database error
+*/
+func UpdateEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, newLink string) (*Entry, *Link, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := entry.clone()
+
+	link, err := ParseLink(newLink)
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.removeLinks(func(l *Link) bool { return l.Kind == link.Kind })
+	if link, err = entry.AddLink(newLink, link.Kind); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// Execute update statements
+	if err = entry.writeLinks(tx); err != nil {
+		return nil, nil, err
+	}
+	if err = recordAction(tx, ACTION_UPDATE, before); err != nil {
+		return nil, nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_UPDATE, before); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	slog.Debug("entry.UpdateEntry", "user", userID, "title", entry.Title, "category", entry.Category, "year", entry.Year, "newLink", link.URL)
+	return entry, link, nil
+}
+
+/*
+Mark an entry as completed in the database and add a viewing to its watch log
+
+Marking an entry done again is a rewatch, it keeps its first completion date.
 
 Params:
 
 	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
 	userID:		user ID of the entry
 	title:		title of the entry
-	category:	category of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	note:		optional note about the viewing
 
 Returns:
 
+	*Entry:	ptr to the completed entry, with its watch log
 	error:	error object
 */
-func DoneEntry(db *sql.DB, userID string, title string, category Category) error {
-	// Prepare update statement
-	query := "UPDATE entries SET done = 1 WHERE userID = ? and title = ? and category = ?"
-	statement, err := db.Prepare(query)
+func DoneEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, note string) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer statement.Close()
 
-	_, err = statement.Exec(userID, title, category)
+	tx, err := db.Begin()
 	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = entry.done(tx, actor, note); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("entry.DoneEntry", "user", userID, "title", entry.Title, "category", entry.Category, "year", entry.Year, "watches", len(entry.Watches))
+	return entry, nil
+}
+
+// Same as DoneEntry for an entry loaded with its details, as part of a transaction
+func (e *Entry) done(tx *sql.Tx, actor *Actor, note string) error {
+	before := e.clone()
+
+	now := time.Now().UTC()
+	query := "UPDATE entries SET done = 1, doneDate = COALESCE(doneDate, ?) WHERE " + ENTRY_KEY
+	if _, err := tx.Exec(query, append([]any{now}, e.key()...)...); err != nil {
+		return err
+	}
+	if err := e.addWatch(tx, &Watch{Date: &now, Rating: e.Rating, Note: note}); err != nil {
+		return err
+	}
+	if err := recordAction(tx, ACTION_DONE, before); err != nil {
+		return err
+	}
+	if err := recordChange(tx, actor, ACTION_DONE, before); err != nil {
 		return err
 	}
 
-	slog.Debug("entry.DoneEntry", "user", userID, "title", title, "category", category)
+	e.Done = true
+	if e.DoneDate == nil {
+		e.DoneDate = &now
+	}
 	return nil
 }
 
 /*
 Rate an entry in the database
 
+The rating also goes on the entry's latest viewing, if it was watched.
+
 Params:
 
 	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
 	userID:		user ID of the entry
 	title:		title of the entry
-	category:	category of the entry
-	rating:		rating to update the entry with
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	rating:		rating to update the entry with, between MIN_RATING and MAX_RATING
 
 Returns:
 
-	error:	error object
+	error:	InvalidRatingError, EntryNotFoundError, AmbiguousEntryError or a database error
 */
-func RateEntry(db *sql.DB, userID string, title string, category Category, rating int) error {
-	// Prepare update statement
-	query := "UPDATE entries SET rating = ? WHERE userID = ? and title = ? and category = ?"
-	statement, err := db.Prepare(query)
+func RateEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, rating int) error {
+	if rating < MIN_RATING || rating > MAX_RATING {
+		return &InvalidRatingError{rating}
+	}
+
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
 	if err != nil {
 		return err
 	}
-	defer statement.Close()
 
-	_, err = statement.Exec(rating, userID, title, category)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE entries SET rating = ? WHERE "+ENTRY_KEY, append([]any{rating}, entry.key()...)...)
 	if err != nil {
 		return err
 	}
+	_, err = tx.Exec("UPDATE watches SET rating = ? WHERE watchID = (SELECT MAX(watchID) FROM watches WHERE "+ENTRY_KEY+")",
+		append([]any{rating}, entry.key()...)...)
+	if err != nil {
+		return err
+	}
+	if err = recordAction(tx, ACTION_RATE, entry); err != nil {
+		return err
+	}
+	if err = recordChange(tx, actor, ACTION_RATE, entry); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 
-	slog.Debug("entry.RateEntry", "user", userID, "title", title, "category", category, "rating", rating)
+	slog.Debug("entry.RateEntry", "user", userID, "title", entry.Title, "category", entry.Category, "year", entry.Year, "rating", rating)
 	return nil
 }
 
+/*
+Set the runtime of an entry in the database
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	runtime:	runtime in minutes
+
+Returns:
+
+	*Entry:	ptr to the updated entry
+	error:	error object
+*/
+func RuntimeEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, runtime int) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE entries SET runtime = ? WHERE "+ENTRY_KEY, append([]any{runtime}, entry.key()...)...)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_RUNTIME, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	entry.Runtime = runtime
+	slog.Debug("entry.RuntimeEntry", "user", userID, "title", entry.Title, "category", entry.Category, "runtime", runtime)
+	return entry, nil
+}
+
+/*
+Find a single entry in the database
+
+A year also matches entries whose year is unknown, unless the user also has the title with that exact year.
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	viewer:		who is looking the entry up, the owner's privacy setting applies to anyone else
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+
+Returns:
+
+	*Entry:	ptr to the matching entry
+	error:	PrivateWatchlistError, EntryNotFoundError, AmbiguousEntryError or a database error
+*/
+func FindEntry(db *sql.DB, viewer *Viewer, userID string, title string, category Category, year int) (*Entry, error) {
+	if err := CanView(db, viewer, userID); err != nil {
+		return nil, err
+	}
+	return findEntry(db, false, userID, title, category, year)
+}
+
+// Find a single entry on the watchlist, or in the trash if trashed is true
+func findEntry(db *sql.DB, trashed bool, userID string, title string, category Category, year int) (*Entry, error) {
+	query := "SELECT " + ENTRY_COLUMNS + " FROM entries WHERE userID = ? AND title = ? AND (? = '' OR category = ?) AND (? = 0 OR year IN (?, 0))"
+	if trashed {
+		query += " AND deleted IS NOT NULL"
+	} else {
+		query += " AND " + NOT_DELETED
+	}
+
+	entries, err := queryEntries(db, query, userID, title, category, category, year, year)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefer an exact year over unknown years
+	var matches []*Entry
+	for _, e := range entries {
+		if e.Year == year {
+			matches = append(matches, e)
+		}
+	}
+	if year == 0 || len(matches) == 0 {
+		matches = entries
+	}
+
+	switch len(matches) {
+	case 0:
+		if trashed {
+			return nil, &NotInTrashError{userID, displayTitle(title, year)}
+		}
+		return nil, &EntryNotFoundError{userID, displayTitle(title, year), category}
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, &AmbiguousEntryError{title, len(matches)}
+	}
+}
+
+/*
+Move an entry to a new position in the user's queue
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	position:	new 1-based position, clamped to the size of the queue
+
+Returns:
+
+	*Entry:	ptr to the moved entry (with its new priority)
+	error:	error object
+*/
+func MoveEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, position int) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Load the current queue order, leaving out the entry being moved
+	loaded, err := loadQueue(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []*Entry
+	for _, e := range loaded {
+		if e.Title != entry.Title || e.Category != entry.Category || e.Year != entry.Year {
+			queue = append(queue, e)
+		}
+	}
+
+	// Clamp position and splice the entry back in
+	position = max(1, min(position, len(queue)+1))
+	queue = append(queue[:position-1], append([]*Entry{entry}, queue[position-1:]...)...)
+
+	if err = writeQueue(tx, queue); err != nil {
+		return nil, err
+	}
+
+	if err = recordChange(tx, actor, ACTION_MOVE, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	entry.Priority = position
+	slog.Debug("entry.MoveEntry", "user", userID, "title", entry.Title, "category", entry.Category, "position", position)
+	return entry, nil
+}
+
+// Columns selected when loading full entries, in the order scanEntry expects them
+const ENTRY_COLUMNS = "userID, date, title, category, year, externalID, done, COALESCE(rating, 0), priority, runtime, doneDate, genres, poster, episodes, deleted"
+
+// Condition leaving out entries in the trash, prefix it with the table alias in joins
+const NOT_DELETED = "deleted IS NULL"
+
+// Condition matching a single entry, takes the values from Entry.key
+const ENTRY_KEY = "userID = ? AND title = ? AND category = ? AND year = ?"
+
+// Values for ENTRY_KEY
+func (e *Entry) key() []any {
+	return []any{e.UserID, e.Title, e.Category, e.Year}
+}
+
+// entryKey identifies an entry in maps, ENTRY_KEY's columns as a comparable struct
+type entryKey struct {
+	userID   string
+	title    string
+	category Category
+	year     int
+}
+
+// Key to index an entry by in maps
+func (e *Entry) mapKey() entryKey {
+	return entryKey{e.UserID, e.Title, e.Category, e.Year}
+}
+
+// Copy of an entry whose tags, links, availability and watch log can change without changing the original's
+func (e *Entry) clone() *Entry {
+	c := *e
+	c.Tags = slices.Clone(e.Tags)
+	c.Links = slices.Clone(e.Links)
+	c.Availability = slices.Clone(e.Availability)
+	c.Watches = slices.Clone(e.Watches)
+	return &c
+}
+
+// Common interface of *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Scan a row selected with ENTRY_COLUMNS into an entry
+func scanEntry(row scanner) (*Entry, error) {
+	var (
+		e        Entry
+		doneDate sql.NullTime
+		deleted  sql.NullTime
+		genres   string
+	)
+	err := row.Scan(&e.UserID, &e.Date, &e.Title, &e.Category, &e.Year, &e.ExternalID, &e.Done, &e.Rating, &e.Priority,
+		&e.Runtime, &doneDate, &genres, &e.Poster, &e.Episodes, &deleted)
+	if err != nil {
+		return nil, err
+	}
+
+	if genres != "" {
+		e.Genres = strings.Split(genres, ",")
+	}
+	if doneDate.Valid {
+		e.DoneDate = &doneDate.Time
+	}
+	if deleted.Valid {
+		e.Deleted = &deleted.Time
+	}
+	return &e, nil
+}
+
 // Validator for category struct
 func (c *Category) IsValid() error {
 	switch *c {
@@ -219,6 +743,11 @@ func (e *Entry) IsValid() error {
 		return err
 	}
 
+	// 0 means the year is unknown
+	if e.Year != 0 && (e.Year < MIN_YEAR || e.Year > MAX_YEAR) {
+		return &InvalidYearError{e.Year}
+	}
+
 	if reflect.TypeOf(e.Date).String() != "time.Time" {
 		return &InvalidTimestampError{e.Date.String()}
 	}
@@ -226,11 +755,167 @@ func (e *Entry) IsValid() error {
 	return nil
 }
 
+/*
+Render all of an entry's details as an embedded message
+
+Params:
+
+	loc:	timezone dates are shown in
+
+Returns:
+
+	*discordgo.MessageEmbed:	ptr to the embed
+*/
+func (e *Entry) Embed(loc *time.Location) *discordgo.MessageEmbed {
+	status := "unwatched"
+	if e.Done {
+		status = "done"
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("%s (%s)", e.DisplayTitle(), e.Category),
+		URL:   e.Link,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Status", Value: status, Inline: true},
+			{Name: "Position", Value: fmt.Sprintf("#%d", e.Priority), Inline: true},
+		},
+	}
+	field := func(name string, value string) {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: true})
+	}
+
+	if e.Rating > 0 {
+		field("Rating", fmt.Sprint(e.Rating))
+	}
+	if e.Runtime > 0 {
+		field("Runtime", fmt.Sprintf("%d min", e.Runtime))
+	}
+	if e.Episodes > 0 {
+		field("Episodes", fmt.Sprint(e.Episodes))
+	}
+	if len(e.Genres) > 0 {
+		field("Genres", strings.Join(e.Genres, ", "))
+	}
+	if len(e.Tags) > 0 {
+		field("Tags", strings.Join(e.Tags, ", "))
+	}
+	if len(e.Availability) > 0 {
+		available := make([]string, len(e.Availability))
+		for i, a := range e.Availability {
+			available[i] = a.String()
+		}
+		field("Where to watch", strings.Join(available, "\n"))
+	}
+	field("Added", formatTime(e.Date, loc))
+	if first, last := e.WatchDates(); first != nil {
+		field("First watched", formatTime(*first, loc))
+		if len(e.Watches) > 1 {
+			field("Last watched", formatTime(*last, loc))
+		}
+	} else if e.DoneDate != nil {
+		field("Finished", formatTime(*e.DoneDate, loc))
+	}
+	if e.Rewatches() > 0 {
+		field("Rewatches", fmt.Sprint(e.Rewatches()))
+	}
+	if e.Poster != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: e.Poster}
+	}
+
+	// The title already links to the most relevant link, list every link here
+	if len(e.Links) > 0 {
+		links := make([]string, len(e.Links))
+		for i, link := range e.Links {
+			links[i] = fmt.Sprintf("%s: %s", link.Kind, link.URL)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Links", Value: strings.Join(links, "\n")})
+	}
+
+	// Latest viewings first
+	if len(e.Watches) > 0 {
+		var watches []string
+		for i := len(e.Watches) - 1; i >= 0 && len(watches) < WATCH_LOG_SIZE; i-- {
+			watches = append(watches, e.Watches[i].Format(loc))
+		}
+		if more := len(e.Watches) - len(watches); more > 0 {
+			watches = append(watches, fmt.Sprintf("+%d more", more))
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Watch log", Value: strings.Join(watches, "\n")})
+	}
+
+	return embed
+}
+
 // Stringer for entry struct
 func (e *Entry) String() string {
 	if e.Link != "" {
-		return fmt.Sprintf("%s (%s)\n%s\n", e.Title, e.Category, e.Link)
+		return fmt.Sprintf("%s (%s)\n%s\n", e.DisplayTitle(), e.Category, e.Link)
 	} else {
-		return fmt.Sprintf("%s (%s)\n", e.Title, e.Category)
+		return fmt.Sprintf("%s (%s)\n", e.DisplayTitle(), e.Category)
+	}
+}
+
+// Title with its release year when known, ex. Dune (2021), in the same form SplitYear accepts
+func (e *Entry) DisplayTitle() string {
+	return displayTitle(e.Title, e.Year)
+}
+
+func displayTitle(title string, year int) string {
+	if year == 0 {
+		return title
+	}
+	return fmt.Sprintf("%s (%d)", title, year)
+}
+
+// Release years accepted from users, anything else is treated as part of the title
+const (
+	MIN_YEAR = 1870
+	MAX_YEAR = 2100
+)
+
+// Ratings accepted from users, 0 is stored for unrated entries
+const (
+	MIN_RATING = 1
+	MAX_RATING = 10
+)
+
+// Matches a year on its own or in parentheses, ex. 2021 or (2021)
+var YEAR_PATTERN = regexp.MustCompile(`^\(?(\d{4})\)?$`)
+
+// Matches a title ending with a year in parentheses, ex. Dune (2021)
+var TITLE_YEAR_PATTERN = regexp.MustCompile(`^(.+?)\s*\((\d{4})\)$`)
+
+// Parse a release year (ex. 2021 or (2021)), 0 if the input isn't one
+func ParseYear(input string) int {
+	match := YEAR_PATTERN.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return 0
+	}
+
+	year, _ := strconv.Atoi(match[1])
+	if year < MIN_YEAR || year > MAX_YEAR {
+		return 0
+	}
+	return year
+}
+
+/*
+Split a release year off the end of a title
+
+Example:
+
+	SplitYear("Dune (2021)") == "Dune", 2021
+	SplitYear("Dune") == "Dune", 0
+*/
+func SplitYear(title string) (string, int) {
+	match := TITLE_YEAR_PATTERN.FindStringSubmatch(strings.TrimSpace(title))
+	if match == nil {
+		return title, 0
+	}
+
+	year := ParseYear(match[2])
+	if year == 0 {
+		return title, 0
 	}
+	return match[1], year
 }
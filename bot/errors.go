@@ -48,6 +48,157 @@ type EntryNotFoundError struct {
 	category Category
 }
 
+type AmbiguousEntryError struct {
+	title   string
+	matches int
+}
+
+type EmptyWatchlistError struct {
+	userID string
+}
+
+type NoMatchingEntriesError struct {
+	userID string
+}
+
+type InvalidWeightingError struct {
+	weighting *Weighting
+}
+
+type InvalidPickOptionError struct {
+	option string
+}
+
+type InvalidDateError struct {
+	date string
+}
+
+type InvalidRepeatError struct {
+	repeat *Repeat
+}
+
+type ReminderNotFoundError struct {
+	userID     string
+	reminderID int64
+}
+
+type PartyNotFoundError struct {
+	partyID int64
+}
+
+type PartyClosedError struct {
+	partyID int64
+	state   PartyState
+}
+
+type NotPartyHostError struct {
+	userID  string
+	partyID int64
+}
+
+type InvalidRSVPError struct {
+	rsvp *RSVP
+}
+
+type InvalidTimezoneError struct {
+	timezone string
+}
+
+type InvalidTokenError struct{}
+
+type ShareNotFoundError struct{}
+
+type InvalidPrivacyError struct {
+	privacy Privacy
+}
+
+type PrivateWatchlistError struct {
+	userID string
+}
+
+type MetadataNotFoundError struct {
+	id string
+}
+
+type InvalidYearError struct {
+	year int
+}
+
+type InvalidRatingError struct {
+	rating int
+}
+
+type InvalidLinkError struct {
+	link string
+}
+
+type InvalidLinkKindError struct {
+	kind *LinkKind
+}
+
+type LinkNotFoundError struct {
+	title  string
+	target string
+}
+
+type InvalidServiceError struct {
+	service string
+}
+
+type InvalidRegionError struct {
+	region string
+}
+
+type InvalidOfferError struct {
+	offer *Offer
+}
+
+type AvailabilityNotFoundError struct {
+	title   string
+	service string
+}
+
+type NoAvailabilityProviderError struct{}
+
+type NothingToUndoError struct {
+	userID string
+}
+
+type EntryInTrashError struct {
+	title string
+}
+
+type NotInTrashError struct {
+	userID string
+	title  string
+}
+
+type NotGuildAdminError struct {
+	username string
+}
+
+type InvalidNumberError struct {
+	name  string
+	value string
+}
+
+type InvalidPositionError struct {
+	position int
+	last     int
+}
+
+type NotInGuildError struct {
+	command string
+}
+
+type CannotDMError struct {
+	username string
+}
+
+type PendingAddNotFoundError struct {
+	pendingID int64
+}
+
 type NotEnoughArgumentsError struct {
 	message string
 }
@@ -78,6 +229,154 @@ func (e *EntryNotFoundError) Error() string {
 	return fmt.Sprintf("Entry not found for %s: %s (%s)", e.userID, e.title, e.category)
 }
 
+func (e *AmbiguousEntryError) Error() string {
+	return fmt.Sprintf("%d entries named %s, please specify a category or release year", e.matches, e.title)
+}
+
+func (e *EmptyWatchlistError) Error() string {
+	return fmt.Sprintf("No entries found for %s", e.userID)
+}
+
+func (e *NoMatchingEntriesError) Error() string {
+	return fmt.Sprintf("No entries match the given filters for %s", e.userID)
+}
+
+func (e *InvalidWeightingError) Error() string {
+	return fmt.Sprintf("Invalid weight option: %s", *e.weighting)
+}
+
+func (e *InvalidPickOptionError) Error() string {
+	return fmt.Sprintf("Invalid random option: %s", e.option)
+}
+
+func (e *InvalidDateError) Error() string {
+	return fmt.Sprintf("Invalid date (try tomorrow 8pm, friday 20:00 or in 2 hours): %s", e.date)
+}
+
+func (e *InvalidRepeatError) Error() string {
+	return fmt.Sprintf("Invalid repeat option: %s", *e.repeat)
+}
+
+func (e *ReminderNotFoundError) Error() string {
+	return fmt.Sprintf("Reminder not found for %s: #%d", e.userID, e.reminderID)
+}
+
+func (e *PartyNotFoundError) Error() string {
+	return fmt.Sprintf("Watch party not found: #%d", e.partyID)
+}
+
+func (e *PartyClosedError) Error() string {
+	return fmt.Sprintf("Watch party #%d is %s", e.partyID, e.state)
+}
+
+func (e *NotPartyHostError) Error() string {
+	return fmt.Sprintf("%s is not the host of watch party #%d", e.userID, e.partyID)
+}
+
+func (e *InvalidRSVPError) Error() string {
+	return fmt.Sprintf("Invalid RSVP: %s", *e.rsvp)
+}
+
+func (e *InvalidTimezoneError) Error() string {
+	return fmt.Sprintf("Invalid timezone (try a name like America/Edmonton or Europe/London): %s", e.timezone)
+}
+
+func (e *InvalidTokenError) Error() string {
+	return "Invalid API token"
+}
+
+func (e *ShareNotFoundError) Error() string {
+	return "Share link not found"
+}
+
+func (e *InvalidPrivacyError) Error() string {
+	return fmt.Sprintf("Invalid privacy (must be private, guild or link): %s", e.privacy)
+}
+
+func (e *PrivateWatchlistError) Error() string {
+	return fmt.Sprintf("This watchlist is private: %s", e.userID)
+}
+
+func (e *MetadataNotFoundError) Error() string {
+	return fmt.Sprintf("No metadata found: %s", e.id)
+}
+
+func (e *InvalidYearError) Error() string {
+	return fmt.Sprintf("Invalid year: %d (expected %d-%d)", e.year, MIN_YEAR, MAX_YEAR)
+}
+
+func (e *InvalidRatingError) Error() string {
+	return fmt.Sprintf("Invalid rating: %d (expected %d-%d)", e.rating, MIN_RATING, MAX_RATING)
+}
+
+func (e *InvalidLinkError) Error() string {
+	return fmt.Sprintf("Invalid link: %s (expected a web address, ex. https://www.imdb.com/title/tt0068646/)", e.link)
+}
+
+func (e *InvalidLinkKindError) Error() string {
+	return fmt.Sprintf("Invalid link kind: %s (expected trailer/info/streaming/other)", *e.kind)
+}
+
+func (e *LinkNotFoundError) Error() string {
+	return fmt.Sprintf("%s has no %s link", e.title, e.target)
+}
+
+func (e *InvalidServiceError) Error() string {
+	return fmt.Sprintf("Invalid streaming service: %s", e.service)
+}
+
+func (e *InvalidRegionError) Error() string {
+	return fmt.Sprintf("Invalid region: %s (expected a two letter country code, ex. US)", e.region)
+}
+
+func (e *InvalidOfferError) Error() string {
+	return fmt.Sprintf("Invalid offer: %s (expected free/rent/buy)", *e.offer)
+}
+
+func (e *AvailabilityNotFoundError) Error() string {
+	return fmt.Sprintf("%s isn't on %s", e.title, e.service)
+}
+
+func (e *NoAvailabilityProviderError) Error() string {
+	return "Streaming lookups are disabled"
+}
+
+func (e *NothingToUndoError) Error() string {
+	return fmt.Sprintf("Nothing to undo for %s in the last %s", e.userID, UNDO_WINDOW)
+}
+
+func (e *EntryInTrashError) Error() string {
+	return fmt.Sprintf("%s is in the trash, restore it instead of adding it again", e.title)
+}
+
+func (e *NotInTrashError) Error() string {
+	return fmt.Sprintf("%s isn't in the trash for %s", e.title, e.userID)
+}
+
+func (e *NotGuildAdminError) Error() string {
+	return fmt.Sprintf("Only server admins can view the audit log, %s isn't one", e.username)
+}
+
+func (e *InvalidNumberError) Error() string {
+	return fmt.Sprintf("Invalid %s: %s (expected a number)", e.name, e.value)
+}
+
+func (e *InvalidPositionError) Error() string {
+	return fmt.Sprintf("Invalid position: %d (your watchlist only goes up to #%d, a release year goes before the category)", e.position, e.last+1)
+}
+
+func (e *NotInGuildError) Error() string {
+	return fmt.Sprintf("./watchlist %s can only be used in a server", e.command)
+}
+
+func (e *CannotDMError) Error() string {
+	return fmt.Sprintf("Couldn't DM %s, check your privacy settings", e.username)
+}
+
+func (e *PendingAddNotFoundError) Error() string {
+	return fmt.Sprintf("Duplicate prompt #%d has expired, was already answered or isn't yours", e.pendingID)
+}
+
 func (e *NotEnoughArgumentsError) Error() string {
 	return fmt.Sprintf("Received: %s", e.message)
 }
@@ -22,9 +22,11 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
-	"math/rand"
+	"math"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -35,15 +37,40 @@ const (
 	// Commands that the user will use to interact with the bot
 	ENTRYPOINT = "./watchlist"
 
-	ADD_COMMAND     = "add"     // Add entry to watchlist
-	DELETE_COMMAND  = "delete"  // Delete item from watchlist
-	VIEW_COMMAND    = "view"    // View watchlist
-	UPDATE_COMMAND  = "update"  // Update the link for an entry
-	DONE_COMMAND    = "done"    // Mark entry as complete
-	RATE_COMMAND    = "rate"    // Rate an entry
-	RANDOM_COMMAND  = "random"  // Get a random movie from watchlist
-	CONTACT_COMMAND = "contact" // Get contact info for the developer
-	HELP_COMMAND    = "help"    // Display help message
+	ADD_COMMAND         = "add"         // Add entry to watchlist
+	DELETE_COMMAND      = "delete"      // Delete item from watchlist
+	VIEW_COMMAND        = "view"        // View watchlist
+	INFO_COMMAND        = "info"        // View all the details of an entry
+	UPDATE_COMMAND      = "update"      // Update the link for an entry
+	DONE_COMMAND        = "done"        // Mark entry as complete
+	RATE_COMMAND        = "rate"        // Rate an entry
+	MOVE_COMMAND        = "move"        // Move an entry up/down the watchlist
+	TAG_COMMAND         = "tag"         // Tag an entry
+	UNTAG_COMMAND       = "untag"       // Remove a tag from an entry
+	LINK_COMMAND        = "link"        // Add a link to an entry
+	UNLINK_COMMAND      = "unlink"      // Remove links from an entry
+	STREAM_COMMAND      = "stream"      // Note or look up where an entry is streaming
+	UNSTREAM_COMMAND    = "unstream"    // Remove a streaming service from an entry
+	UNDO_COMMAND        = "undo"        // Undo your last delete/done/rate/update
+	TRASH_COMMAND       = "trash"       // List deleted entries
+	RESTORE_COMMAND     = "restore"     // Restore a deleted entry from the trash
+	HISTORY_COMMAND     = "history"     // View the changes made to an entry
+	AUDIT_COMMAND       = "audit"       // View the changes made in this server (admins only)
+	RUNTIME_COMMAND     = "runtime"     // Set the runtime of an entry
+	RANDOM_COMMAND      = "random"      // Get a random movie from watchlist
+	REMIND_COMMAND      = "remind"      // Schedule, list and cancel reminders
+	PARTY_COMMAND       = "party"       // Schedule a group watch with RSVPs
+	TIMEZONE_COMMAND    = "timezone"    // View or set your timezone
+	PRIVACY_COMMAND     = "privacy"     // View or set who can see your watchlist
+	TOKEN_COMMAND       = "token"       // Get or revoke a personal API token
+	SHARE_COMMAND       = "share"       // Get or revoke a read-only web link to a watchlist
+	STATS_COMMAND       = "stats"       // View statistics about your watchlist
+	LEADERBOARD_COMMAND = "leaderboard" // View this server's most watched and top rated titles
+	RECAP_COMMAND       = "recap"       // View everything you (or this server) completed in a year
+	COMPARE_COMMAND     = "compare"     // Compare your watchlist with another member's
+	RECOMMEND_COMMAND   = "recommend"   // Get titles this server rated highly that you haven't added
+	CONTACT_COMMAND     = "contact"     // Get contact info for the developer
+	HELP_COMMAND        = "help"        // Display help message
 
 	// LETTERBOXD_COMMAND 	= "letterboxd"	// random movie from letterboxd list
 	// IMDB_COMMAND 		= "imdb"		// random movie from imdb list
@@ -56,6 +83,57 @@ const (
 //	\S+         matches 1+ substrings separated by whitespaces
 var REGEX_PATTERN = regexp.MustCompile(`("[^"]+"|\S+)`)
 
+// Split a message into arguments, removing the quotes around quoted arguments
+func parseArgs(content string) []string {
+	args := REGEX_PATTERN.FindAllString(content, -1)
+	for i, arg := range args {
+		args[i] = strings.Trim(arg, `"`)
+	}
+	return args
+}
+
+// Actor for changes made with a message
+func messageActor(m *discordgo.MessageCreate) *Actor {
+	return &Actor{UserID: m.Author.ID, Source: SOURCE_DISCORD, GuildID: m.GuildID, ChannelID: m.ChannelID}
+}
+
+// Who is reading watchlists with a message
+func messageViewer(m *discordgo.MessageCreate) *Viewer {
+	return &Viewer{UserID: m.Author.ID, GuildID: m.GuildID}
+}
+
+// Take a release year off the title argument, given as part of the title or right after it
+//
+//	./watchlist done "Dune (2021)" movie	-> []string{"./watchlist", "done", "Dune", "movie"}, 2021
+//	./watchlist done "Dune" 2021 movie		-> []string{"./watchlist", "done", "Dune", "movie"}, 2021
+func splitTitleYear(args []string) ([]string, int) {
+	if len(args) < 3 {
+		return args, 0
+	}
+
+	if title, year := SplitYear(args[2]); year != 0 {
+		args[2] = title
+		return args, year
+	}
+	if len(args) >= 4 {
+		if year := ParseYear(args[3]); year != 0 {
+			return append(args[:3], args[4:]...), year
+		}
+	}
+	return args, 0
+}
+
+// Split the arguments of "<title> <value>" and "<title> <category> <value>" commands
+//
+//	args1 = []string{"./watchlist", command, title, value}
+//	args2 = []string{"./watchlist", command, title, category, value}
+func parseTarget(args []string) (title string, category Category, value string) {
+	if len(args) == 4 {
+		return args[2], "", args[3]
+	}
+	return args[2], Category(args[3]), args[4]
+}
+
 /*
 Main handler for the bot that will delegate to private handlers based on user input
 
@@ -71,16 +149,24 @@ func MasterHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 	}
 
 	// args = []string{"./watchlist <command> <arg1> <arg2> ..."}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	args := parseArgs(m.Content)
+
+	// Ignore messages not addressed to us
+	if len(args) == 0 || args[0] != ENTRYPOINT {
+		return
+	}
 
 	// Send help to messages without commands
 	if len(args) < 2 {
 		helpHandler(s, m)
+		return
 	}
 
-	// Ignore messages not addressed to us
-	if args[0] != ENTRYPOINT {
-		return
+	// Remember who uses the bot in each guild for guild-wide views
+	if m.GuildID != "" {
+		if err := RecordMember(db, m.GuildID, m.Author.ID, m.Author.Username); err != nil {
+			slog.Error("handlers.MasterHandler", "msg", err)
+		}
 	}
 
 	// Fire the correct handler based on given command
@@ -91,14 +177,58 @@ func MasterHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 		deleteHandler(db, s, m)
 	case VIEW_COMMAND:
 		viewHandler(db, s, m)
+	case INFO_COMMAND:
+		infoHandler(db, s, m)
 	case UPDATE_COMMAND:
 		updateHandler(db, s, m)
 	case DONE_COMMAND:
 		doneHandler(db, s, m)
 	case RATE_COMMAND:
 		rateHandler(db, s, m)
+	case MOVE_COMMAND:
+		moveHandler(db, s, m)
+	case TAG_COMMAND, UNTAG_COMMAND:
+		tagHandler(db, s, m)
+	case LINK_COMMAND, UNLINK_COMMAND:
+		linkHandler(db, s, m)
+	case STREAM_COMMAND, UNSTREAM_COMMAND:
+		streamHandler(db, s, m)
+	case UNDO_COMMAND:
+		undoHandler(db, s, m)
+	case TRASH_COMMAND:
+		trashHandler(db, s, m)
+	case RESTORE_COMMAND:
+		restoreHandler(db, s, m)
+	case HISTORY_COMMAND:
+		historyHandler(db, s, m)
+	case AUDIT_COMMAND:
+		auditHandler(db, s, m)
+	case RUNTIME_COMMAND:
+		runtimeHandler(db, s, m)
 	case RANDOM_COMMAND:
 		randomHandler(db, s, m)
+	case REMIND_COMMAND:
+		remindHandler(db, s, m)
+	case PARTY_COMMAND:
+		partyHandler(db, s, m)
+	case TIMEZONE_COMMAND:
+		timezoneHandler(db, s, m)
+	case PRIVACY_COMMAND:
+		privacyHandler(db, s, m)
+	case TOKEN_COMMAND:
+		tokenHandler(db, s, m)
+	case SHARE_COMMAND:
+		shareHandler(db, s, m)
+	case STATS_COMMAND:
+		statsHandler(db, s, m)
+	case LEADERBOARD_COMMAND:
+		leaderboardHandler(db, s, m)
+	case RECAP_COMMAND:
+		recapHandler(db, s, m)
+	case COMPARE_COMMAND:
+		compareHandler(db, s, m)
+	case RECOMMEND_COMMAND:
+		recommendHandler(db, s, m)
 	case HELP_COMMAND:
 		helpHandler(s, m)
 	case CONTACT_COMMAND:
@@ -112,47 +242,129 @@ func MasterHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 /*
 Creates an entry and adds it to the watchlist, then sends a confirmation message
 
+If the title looks like one that's already on the watchlist, the existing entry is shown
+with buttons to keep both, put the new link on the existing entry, or cancel.
+
 Usage:
 
-	./watchlist add <title> <category> <link?>
+	./watchlist add <title> <year?> <category> <position?> <link?>
 
 Example:
 
 	./watchlist add "The Godfather" movie
+	./watchlist add "The Godfather" movie 1
 	./watchlist add "The Godfather" movie "https://www.imdb.com/title/tt0133093/"
+	./watchlist add "The Godfather" movie 1 "https://www.imdb.com/title/tt0133093/"
+	./watchlist add "Dune (2021)" movie
+	./watchlist add "Dune" 2021 movie
 */
 func addHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
-	// args = []string{"./watchlist", add, title, category, link?}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	// args = []string{"./watchlist", add, title, category, position?, link?}
+	args, year := splitTitleYear(parseArgs(m.Content))
 	if len(args) < 4 {
-		slog.Error("handlers.addHandler", "msg", NotEnoughArgumentsError{m.Content})
+		replyError(s, m, ADD_COMMAND, &NotEnoughArgumentsError{m.Content})
 		return
 	} // Ensure we have at least a title and category
 
 	title := args[2]
 	category := Category(args[3])
 
+	var (
+		position int
+		link     string
+	)
+
+	// case: ./watchlist add <movie> <category> <position> <link?>
 	// case: ./watchlist add <movie> <category> <link>
-	var link string
-	if len(args) == 5 {
-		link = args[4]
+	if len(args) >= 5 {
+		if p, err := strconv.Atoi(args[4]); err == nil {
+			position = p
+			if len(args) >= 6 {
+				link = args[5]
+			}
+		} else {
+			link = args[4]
+		}
+	}
+
+	// A number past the end of the queue is more likely a year typed after the category, so don't guess
+	if position > 0 {
+		last, err := queueLength(db, m.Author.ID)
+		if err != nil {
+			replyError(s, m, ADD_COMMAND, err)
+			return
+		}
+		if position > last+1 {
+			replyError(s, m, ADD_COMMAND, &InvalidPositionError{position, last})
+			return
+		}
 	}
 
 	entry := &Entry{
 		UserID:   m.Author.ID,
 		Title:    title,
 		Category: category,
+		Year:     year,
 		Date:     time.Now(),
-		Link:     link,
+		Priority: position,
+	}
+	if err := entry.IsValid(); err != nil {
+		replyError(s, m, ADD_COMMAND, err)
+		return
+	}
+
+	// Reject links that aren't web addresses, and clean up links to sites we know
+	if link != "" {
+		if _, err := entry.AddLink(link, ""); err != nil {
+			replyError(s, m, ADD_COMMAND, err)
+			return
+		}
+	}
+
+	// Normalize the title and fill in details from the metadata provider
+	if _, err := entry.Enrich(METADATA_PROVIDER); err != nil {
+		slog.Error("handlers.addHandler", "msg", err)
+	}
+
+	// Ask before adding something that's already on the watchlist
+	duplicates, err := FindDuplicates(db, entry)
+	if err != nil {
+		replyError(s, m, ADD_COMMAND, err)
+		return
+	}
+	if len(duplicates) > 0 {
+		existing := duplicates[0]
+		pending, err := SavePendingAdd(db, entry, existing)
+		if err != nil {
+			replyError(s, m, ADD_COMMAND, err)
+			return
+		}
+
+		message := fmt.Sprintf("```%s looks like %s, already on your watchlist at #%d```", entry.DisplayTitle(), existing.DisplayTitle(), existing.Priority)
+		if entry.sameKey(existing) {
+			message = fmt.Sprintf("```%s is already on your watchlist at #%d```", existing.DisplayTitle(), existing.Priority)
+		}
+
+		slog.Info("handlers.addHandler", "user", m.Author.Username, "entry", entry, "duplicates", len(duplicates))
+		s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+			Content:    message,
+			Embeds:     []*discordgo.MessageEmbed{existing.Embed(userLocation(db, m.Author.ID))},
+			Components: pending.Components(),
+			Reference:  m.Reference(),
+		})
+		return
 	}
 
 	// Add to database
-	entry.Add(db)
+	if err := entry.Add(db, messageActor(m)); err != nil {
+		replyError(s, m, ADD_COMMAND, err)
+		return
+	}
 
 	// Log and send a confirmation message
 	slog.Info("handlers.AddHandler", "user", m.Author.Username, "entry", entry)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```added %s to your watchlist```", entry.Title))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```added %s to your watchlist at #%d```", entry.DisplayTitle(), entry.Priority))
 }
 
 /*
@@ -171,9 +383,9 @@ Example:
 func deleteHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	// args = []string{"./watchlist", title, category?}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	args, year := splitTitleYear(parseArgs(m.Content))
 	if len(args) < 3 {
-		slog.Error("handlers.deleteHandler", "msg", NotEnoughArgumentsError{m.Content})
+		replyError(s, m, DELETE_COMMAND, &NotEnoughArgumentsError{m.Content})
 		return
 	} // Ensure we have at least a title
 
@@ -186,9 +398,10 @@ func deleteHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 	}
 
 	// Delete entry
-	err := DeleteEntry(db, m.Author.ID, title, category)
+	err := DeleteEntry(db, messageActor(m), m.Author.ID, title, category, year)
 	if err != nil {
-		slog.Error("handlers.DeleteHandler", "msg", err)
+		replyError(s, m, DELETE_COMMAND, err)
+		return
 	}
 
 	// Log and send a confirmation message
@@ -197,7 +410,7 @@ func deleteHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 		"title", title,
 		"category", category,
 	)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```deleted %s from your watchlist```", title))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```moved %s to the trash```", displayTitle(title, year)))
 }
 
 /*
@@ -205,7 +418,7 @@ Displays the watchlist (sorted by position), then sends a confirmation message
 
 Usage:
 
-	./watchlist view <sort_by?>
+	./watchlist view <sort_by?> <on:service(:region)?>
 
 Example:
 
@@ -213,32 +426,54 @@ Example:
 	./watchlist view title
 	./watchlist view date
 	./watchlist view category
+	./watchlist view priority
+	./watchlist view on:netflix
+	./watchlist view date on:prime:us
 */
 func viewHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
-	// args = []string{"./watchlist", "view", sort_by}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	// args = []string{"./watchlist", "view", sort_by?, on:service?}
+	args := parseArgs(m.Content)
 	// no need to verify args because we have a default value for sort_by
 
 	sort_by := SORT_TITLE
-	if len(args) >= 3 {
-		sort_by = SortBy(args[2])
+	var service, region string
+	for _, arg := range args[2:] {
+		if value, found := strings.CutPrefix(strings.ToLower(arg), "on:"); found {
+			service, region = ParseServiceFilter(value)
+		} else {
+			sort_by = SortBy(arg)
+		}
+	}
+	if err := sort_by.IsValid(); err != nil {
+		replyError(s, m, VIEW_COMMAND, err)
+		return
 	}
 
 	// Fetch watchlist (including watched items) & sort
-	watchlist, err := FetchWatchlist(db, m.Author.ID, true)
+	watchlist, err := FetchWatchlist(db, messageViewer(m), m.Author.ID, true)
 	if err != nil {
-		slog.Error("handlers.viewHandler", "msg", err)
+		replyError(s, m, VIEW_COMMAND, err)
+		return
 	}
 
+	if service != "" {
+		watchlist.FilterService(service, region)
+	}
 	watchlist.Sort(sort_by)
 
 	// Convert watchlist entries into a list of embed fields
 	var embedFields []*discordgo.MessageEmbedField
 	for _, entry := range watchlist.Entries {
+		// Only the most relevant link fits, the rest are shown by the info command
+		value := fmt.Sprintf("(%s) %s", entry.Category, entry.Link)
+		if more := len(entry.Links) - 1; more > 0 {
+			value += fmt.Sprintf(" +%d more", more)
+		}
+
 		embedFields = append(embedFields, &discordgo.MessageEmbedField{
-			Name:   entry.Title,
-			Value:  fmt.Sprintf("(%s) %s)", entry.Category, entry.Link),
+			Name:   entry.DisplayTitle(),
+			Value:  value,
 			Inline: true,
 		})
 	}
@@ -278,9 +513,9 @@ func updateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 
 	// args1 = []string{"./watchlist", update, title, category}
 	// args2 = []string{"./watchlist", update, title, category, new_link}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	args, year := splitTitleYear(parseArgs(m.Content))
 	if len(args) < 4 {
-		slog.Error("handlers.updateHandler", "msg", NotEnoughArgumentsError{m.Content})
+		replyError(s, m, UPDATE_COMMAND, &NotEnoughArgumentsError{m.Content})
 		return
 	} // Ensure we have at least a title and category
 
@@ -302,37 +537,41 @@ func updateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate)
 		newLink = args[4]
 	}
 
-	// Update database
-	err := UpdateEntry(db, m.Author.ID, title, category, newLink)
+	// Update database, the link is validated and canonicalized on the way in
+	entry, link, err := UpdateEntry(db, messageActor(m), m.Author.ID, title, category, year, newLink)
 	if err != nil {
-		slog.Error("handlers.updateHandler", "msg", err)
+		replyError(s, m, UPDATE_COMMAND, err)
+		return
 	}
 
 	// Log and send a confirmation message
 	slog.Info("handlers.updateHandler", "user", m.Author.Username, "title", title)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```updated %s -> %s```", title, newLink))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```updated %s %s -> %s```", entry.DisplayTitle(), link.Kind, link.URL))
 }
 
 /*
-Marks an entry as complete, then sends a confirmation message
+Marks an entry as complete and logs the viewing, then sends a confirmation message
+Marking a completed entry done again logs a rewatch.
 Usage:
 
 	./watchlist done <title>
 	./watchlist done <title> <category>
+	./watchlist done <title> <category> <note>
 
 Example:
 
 	./watchlist done "The Godfather"
 	./watchlist done "The Godfather" movie
+	./watchlist done "The Godfather" movie "even better the second time"
 */
 func doneHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
-	// args = []string{"./watchlist", "done", title, category}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
-	if len(args) < 4 {
-		slog.Error("handlers.doneHandler", "msg", NotEnoughArgumentsError{m.Content})
+	// args = []string{"./watchlist", "done", title, category?, note?}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 3 {
+		replyError(s, m, DONE_COMMAND, &NotEnoughArgumentsError{m.Content})
 		return
-	} // Ensure we have at least a title and category
+	} // Ensure we have at least a title
 
 	var (
 		title    string
@@ -350,15 +589,23 @@ func doneHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 		category = Category(args[3])
 	}
 
+	// case: ./watchlist done <title> <category> <note>
+	note := strings.Join(args[min(len(args), 4):], " ")
+
 	// Update database
-	err := DoneEntry(db, m.Author.ID, title, category)
+	entry, err := DoneEntry(db, messageActor(m), m.Author.ID, title, category, year, note)
 	if err != nil {
-		slog.Error("handlers.doneHandler", "msg", err)
+		replyError(s, m, DONE_COMMAND, err)
+		return
 	}
 
 	// Log and send a confirmation message
-	slog.Info("handlers.doneHandler", "user", m.Author.Username, "title", title)
-	message := fmt.Sprintf("```completed %s\nrate it with ./watchlist %s %s <rating>```", title, RATE_COMMAND, title)
+	slog.Info("handlers.doneHandler", "user", m.Author.Username, "title", entry.Title)
+	title = entry.DisplayTitle()
+	message := fmt.Sprintf("```completed %s\nrate it with ./watchlist %s \"%s\" <rating>```", title, RATE_COMMAND, title)
+	if entry.Rewatches() > 0 {
+		message = fmt.Sprintf("```rewatched %s, that's %d times\nrate it with ./watchlist %s \"%s\" <rating>```", title, len(entry.Watches), RATE_COMMAND, title)
+	}
 	s.ChannelMessageSend(m.ChannelID, message)
 }
 
@@ -379,9 +626,9 @@ func rateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
 	// args1 = []string{"./watchlist", "rate", title, rating}
 	// args1 = []string{"./watchlist", "rate", title, category, rating}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	args, year := splitTitleYear(parseArgs(m.Content))
 	if len(args) < 4 {
-		slog.Error("handlers.rateHandler", "msg", NotEnoughArgumentsError{m.Content})
+		replyError(s, m, RATE_COMMAND, &NotEnoughArgumentsError{m.Content})
 		return
 	} // Ensure we have at least a title and category
 
@@ -401,8 +648,8 @@ func rateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 		title = args[2]
 		rating, err = strconv.Atoi(args[3])
 		if err != nil {
-			slog.Error("handlers.rateHandler", "msg", err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```invalid rating: %s```", args[3]))
+			replyError(s, m, RATE_COMMAND, &InvalidNumberError{"rating", args[3]})
+			return
 		}
 	}
 
@@ -412,15 +659,16 @@ func rateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 		category = Category(args[3])
 		rating, err = strconv.Atoi(args[4])
 		if err != nil {
-			slog.Error("handlers.rateHandler", "msg", err)
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```invalid rating for %s: %s```", title, args[4]))
+			replyError(s, m, RATE_COMMAND, &InvalidNumberError{"rating", args[4]})
+			return
 		}
 	}
 
 	// Update database
-	err = RateEntry(db, m.Author.ID, title, category, rating)
+	err = RateEntry(db, messageActor(m), m.Author.ID, title, category, year, rating)
 	if err != nil {
-		slog.Error("handlers.rateHandler", "msg", err)
+		replyError(s, m, RATE_COMMAND, err)
+		return
 	}
 
 	// Log and send a confirmation message
@@ -429,97 +677,1512 @@ func rateHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 		"title", title,
 		"rating", rating,
 	)
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```rated %s %d stars```", title, rating))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```rated %s %d stars```", displayTitle(title, year), rating))
+}
+
+/*
+Displays all the details of an entry
+
+Usage:
+
+	./watchlist info <title>
+	./watchlist info <title> <category>
+
+Example:
+
+	./watchlist info "The Godfather"
+*/
+func infoHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "info", title, category?}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 3 {
+		replyError(s, m, INFO_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title
+
+	var category Category
+	if len(args) >= 4 {
+		category = Category(args[3])
+	}
+
+	entry, err := FindEntry(db, messageViewer(m), m.Author.ID, args[2], category, year)
+	if err != nil {
+		replyError(s, m, INFO_COMMAND, err)
+		return
+	}
+
+	// Tags and links are stored separately
+	if err = (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db); err != nil {
+		replyError(s, m, INFO_COMMAND, err)
+		return
+	}
+
+	// Log and send the details as an embedded message
+	slog.Info("handlers.infoHandler", "user", m.Author.Username, "title", entry.Title)
+	s.ChannelMessageSendEmbed(m.ChannelID, entry.Embed(userLocation(db, m.Author.ID)))
+}
+
+/*
+Moves an entry to a new position in the watchlist, then sends a confirmation message
+
+Usage:
+
+	./watchlist move <title> <up|down|top|bottom|position>
+	./watchlist move <title> <category> <up|down|top|bottom|position>
+
+Example:
+
+	./watchlist move "The Godfather" top
+	./watchlist move "The Godfather" movie 3
+*/
+func moveHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args1 = []string{"./watchlist", "move", title, target}
+	// args2 = []string{"./watchlist", "move", title, category, target}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 4 {
+		replyError(s, m, MOVE_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title and target
+
+	// case 1: ./watchlist move <title> <target>
+	// case 2: ./watchlist move <title> <category> <target>
+	title, category, target := parseTarget(args)
+
+	// Relative moves need the entry's current position
+	entry, err := FindEntry(db, messageViewer(m), m.Author.ID, title, category, year)
+	if err != nil {
+		replyError(s, m, MOVE_COMMAND, err)
+		return
+	}
+
+	var position int
+	switch target {
+	case "up":
+		position = entry.Priority - 1
+	case "down":
+		position = entry.Priority + 1
+	case "top":
+		position = 1
+	case "bottom":
+		position = math.MaxInt32 // clamped to the bottom of the queue
+	default:
+		position, err = strconv.Atoi(target)
+		if err != nil {
+			replyError(s, m, MOVE_COMMAND, &InvalidNumberError{"position", target})
+			return
+		}
+	}
+
+	// Update database
+	entry, err = MoveEntry(db, messageActor(m), m.Author.ID, entry.Title, entry.Category, entry.Year, position)
+	if err != nil {
+		replyError(s, m, MOVE_COMMAND, err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.moveHandler", "user", m.Author.Username, "title", title, "position", entry.Priority)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```moved %s to #%d```", entry.DisplayTitle(), entry.Priority))
 }
 
 /*
-Gets a random movie from user's watchlist, then sends a confirmation message
+Gets random movies from user's watchlist, then sends them as embedded messages
+
+Options are key:value pairs that can be combined in any order:
+  - category:<movie/show/anime>		only pick from a category (or just <movie/show/anime>)
+  - tag:<tag>						only pick entries with a tag
+  - runtime:<minutes>				only pick entries at most this long
+  - on:<service(:region)?>			only pick entries streaming on a service
+  - weight:<none/age/priority>		favour older entries or entries near the top (or just top)
+  - count:<n>						pick n different entries
+  - skip:<n>						don't repeat any of your last n picks
 
 Usage:
 
+	./watchlist random <options?>
+
+Example:
+
 	./watchlist random
+	./watchlist random top
+	./watchlist random movie tag:horror runtime:120 count:3 skip:5
+	./watchlist random on:netflix:ca
 */
 func randomHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
-	// Fetch watchlist (excluding watched entries)
-	unwatched, err := FetchWatchlist(db, m.Author.ID, false)
+	// args = []string{"./watchlist", "random", options...}
+	args := parseArgs(m.Content)
+
+	opts, err := ParsePickOptions(args[2:])
 	if err != nil {
-		slog.Error("handlers.randomHandler", "msg", err)
+		replyError(s, m, RANDOM_COMMAND, err)
+		return
 	}
 
-	idx := rand.Intn(len(unwatched.Entries) - 1)
-	entry := unwatched.Entries[idx]
+	// Pick from unwatched entries
+	picks, err := Pick(db, m.Author.ID, opts)
+	if err != nil {
+		replyError(s, m, RANDOM_COMMAND, err)
+		return
+	}
 
 	// Create a thumbnail using the author's avatar
 	thumbnail := &discordgo.MessageEmbedThumbnail{
 		URL: m.Author.AvatarURL(""), // empty string for default avatar size
 	}
 
-	// Create embedded message with entries and thumbnail
-	embed := &discordgo.MessageEmbed{
-		Title:     entry.Title,
-		URL:       entry.Link,
-		Thumbnail: thumbnail,
-		Timestamp: entry.Date.Format(time.RFC3339),
+	// Create an embed with thumbnail for each pick
+	var embeds []*discordgo.MessageEmbed
+	for _, entry := range picks {
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:     entry.DisplayTitle(),
+			URL:       entry.Link,
+			Thumbnail: thumbnail,
+			Timestamp: entry.Date.Format(time.RFC3339),
+		})
 	}
 
-	// Log and send watchlist as an embedded message
-	slog.Info("handlers.randomHandler", "user", m.Author.Username, "unwatched", unwatched)
-	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	// Log and send picks as embedded messages
+	slog.Info("handlers.randomHandler", "user", m.Author.Username, "options", opts, "picks", picks)
+	s.ChannelMessageSendEmbeds(m.ChannelID, embeds)
 }
 
 /*
-Displays the help message
+Adds or removes a tag on an entry, then sends a confirmation message
 
 Usage:
 
-	./watchlist help
-	./watchlist help <command>
+	./watchlist tag <title> <tag>
+	./watchlist tag <title> <category> <tag>
+	./watchlist untag <title> <tag>
+	./watchlist untag <title> <category> <tag>
 
 Example:
 
-	./watchlist help
-	./watchlist help add
+	./watchlist tag "The Godfather" classic
+	./watchlist untag "The Godfather" movie classic
 */
-func helpHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+func tagHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
 
-	// args = []string{"./watchlist", "help", command}
-	args := REGEX_PATTERN.FindAllString(m.Content, -1)
+	// args1 = []string{"./watchlist", "tag"/"untag", title, tag}
+	// args2 = []string{"./watchlist", "tag"/"untag", title, category, tag}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 4 {
+		replyError(s, m, args[1], &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title and tag
 
-	var command string
-	if len(args) >= 3 {
-		command = args[2]
+	title, category, tag := parseTarget(args)
+
+	// Update database
+	var (
+		entry *Entry
+		err   error
+		verb  string
+	)
+	if args[1] == UNTAG_COMMAND {
+		entry, err = UntagEntry(db, messageActor(m), m.Author.ID, title, category, year, tag)
+		verb = "untagged"
+	} else {
+		entry, err = TagEntry(db, messageActor(m), m.Author.ID, title, category, year, tag)
+		verb = "tagged"
+	}
+	if err != nil {
+		replyError(s, m, args[1], err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.tagHandler", "user", m.Author.Username, "title", entry.Title, "tag", tag, "command", args[1])
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s %s with %s```", verb, entry.DisplayTitle(), strings.ToLower(tag)))
+}
+
+/*
+Adds or removes links on an entry, then sends a confirmation message
+
+Usage:
+
+	./watchlist link <title> <link> <kind?>
+	./watchlist link <title> <category> <link> <kind?>
+	./watchlist unlink <title> <link/kind>
+	./watchlist unlink <title> <category> <link/kind>
+
+Example:
+
+	./watchlist link "The Godfather" https://www.youtube.com/watch?v=UaVTIH8mujA
+	./watchlist link "The Godfather" movie https://www.netflix.com/title/60011152 streaming
+	./watchlist unlink "The Godfather" trailer
+*/
+func linkHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args1 = []string{"./watchlist", "link"/"unlink", title, link, kind?}
+	// args2 = []string{"./watchlist", "link"/"unlink", title, category, link, kind?}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 4 {
+		replyError(s, m, args[1], &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title and link
+
+	var (
+		entry   *Entry
+		message string
+		err     error
+	)
+	if args[1] == UNLINK_COMMAND {
+		title, category, target := parseTarget(args)
+
+		var removed []*Link
+		entry, removed, err = UnlinkEntry(db, messageActor(m), m.Author.ID, title, category, year, target)
+		if err == nil {
+			message = fmt.Sprintf("removed %d link(s) from %s", len(removed), entry.DisplayTitle())
+		}
+	} else {
+		// An optional kind goes after the link
+		var kind LinkKind
+		if len(args) >= 5 {
+			if k := LinkKind(strings.ToLower(args[len(args)-1])); k.IsValid() == nil {
+				kind = k
+				args = args[:len(args)-1]
+			}
+		}
+		title, category, raw := parseTarget(args)
+
+		var link *Link
+		entry, link, err = LinkEntry(db, messageActor(m), m.Author.ID, title, category, year, raw, kind)
+		if err == nil {
+			message = fmt.Sprintf("added %s link to %s: %s", link.Kind, entry.DisplayTitle(), link.URL)
+		}
+	}
+	if err != nil {
+		replyError(s, m, args[1], err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.linkHandler", "user", m.Author.Username, "title", entry.Title, "command", args[1])
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", message))
+}
+
+/*
+Notes, looks up or removes where an entry is streaming, then sends a confirmation message
+
+Usage:
+
+	./watchlist stream <title> <category?>
+	./watchlist stream <title> <category?> <service> <free/rent/buy?> <region?>
+	./watchlist stream <title> <category?> lookup <region?>
+	./watchlist unstream <title> <category?> <service> <region?>
+
+Example:
+
+	./watchlist stream "The Godfather"
+	./watchlist stream "The Godfather" netflix
+	./watchlist stream "The Godfather" movie apple rent US
+	./watchlist stream "The Godfather" lookup CA
+	./watchlist unstream "The Godfather" netflix
+*/
+func streamHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "stream"/"unstream", title, category?, service/"lookup"?, options...}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 3 {
+		replyError(s, m, args[1], &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title
+
+	title := args[2]
+	rest := args[3:]
+	var category Category
+	if len(rest) > 0 {
+		if c := Category(strings.ToLower(rest[0])); c.IsValid() == nil {
+			category = c
+			rest = rest[1:]
+		}
+	}
+
+	// Offers and regions can be given in any order after the service
+	a := &Availability{}
+	if len(rest) > 0 {
+		a.Service = rest[0]
+		for _, arg := range rest[1:] {
+			if o := Offer(strings.ToLower(arg)); o.IsValid() == nil {
+				a.Offer = o
+			} else {
+				a.Region = arg
+			}
+		}
 	}
 
-	addMessage := "Adding a movie to your watchlist:\n```./watchlist add <title> <category> <position(optional)> <link(optional)>```"
-	delMessage := "Deleting a movie from your watchlist:\n```./watchlist remove <title>```"
-	viewMessage := "Viewing your watchlist:\n```./watchlist view\n./watchlist view title\n./watchlist view category\n./watchlist view date```"
-	updateMessage := "Updating a movie in your watchlist:\n```./watchlist update <title> <new_link>```"
-	doneMessage := "Marking a movie as completed:\n```./watchlist done <title>\n./watchlist done <title> <category>```"
-	rateMessage := "Rating a movie in your watchlist:\n```./watchlist rate <title> <rating>\n./watchlist rate <title> <category> <rating>```"
-	randomMessage := "Getting a random movie from your watchlist:\n```./watchlist random```"
-	helpMessage := "Displaying this help message:\n```./watchlist help\n./watchlist help <command>```"
-	contactMessage := "Get contact info for the developer:\n```./watchlist contact```"
+	var (
+		entry   *Entry
+		message string
+		err     error
+	)
+	switch {
+	case args[1] == UNSTREAM_COMMAND:
+		if a.Service == "" {
+			replyError(s, m, args[1], &NotEnoughArgumentsError{m.Content})
+			return
+		}
+		entry, err = RemoveAvailability(db, messageActor(m), m.Author.ID, title, category, year, a.Service, a.Region)
+		message = fmt.Sprintf("removed %s from %s", normalizeService(a.Service), displayTitle(title, year))
+
+	case a.Service == "":
+		entry, err = findEntryWithDetails(db, m.Author.ID, title, category, year)
+
+	case strings.ToLower(a.Service) == "lookup":
+		region := a.Region
+		if region == "" {
+			region = DEFAULT_REGION
+		}
+		var found []*Availability
+		entry, found, err = ImportAvailability(db, messageActor(m), AVAILABILITY_PROVIDER, m.Author.ID, title, category, year, region)
+		message = fmt.Sprintf("found %d way(s) to watch %s in %s", len(found), displayTitle(title, year), strings.ToUpper(region))
+
+	default:
+		entry, err = SetAvailability(db, messageActor(m), m.Author.ID, title, category, year, a)
+		message = fmt.Sprintf("%s is on %s", displayTitle(title, year), a)
+	}
+	if err != nil {
+		replyError(s, m, args[1], err)
+		return
+	}
 
-	messages := map[string]string{
-		ADD_COMMAND:     addMessage,
-		DELETE_COMMAND:  delMessage,
-		VIEW_COMMAND:    viewMessage,
-		UPDATE_COMMAND:  updateMessage,
-		DONE_COMMAND:    doneMessage,
-		RATE_COMMAND:    rateMessage,
-		RANDOM_COMMAND:  randomMessage,
-		HELP_COMMAND:    helpMessage,
-		CONTACT_COMMAND: contactMessage,
+	// Follow up with everywhere the entry is streaming now
+	available := make([]string, len(entry.Availability))
+	for i, a := range entry.Availability {
+		available[i] = a.String()
+	}
+	if len(available) == 0 {
+		available = append(available, "not streaming anywhere we know of")
 	}
+	if message != "" {
+		message += "\n\n"
+	}
+	message += fmt.Sprintf("%s:\n%s", entry.DisplayTitle(), strings.Join(available, "\n"))
+
+	// Log and send a confirmation message
+	slog.Info("handlers.streamHandler", "user", m.Author.Username, "title", entry.Title, "command", args[1], "service", a.Service)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", message))
+}
+
+/*
+Undoes the user's most recent deletes, completions, ratings and link updates, then sends a confirmation message
+
+Usage:
+
+	./watchlist undo <count?>
+
+Example:
+
+	./watchlist undo
+	./watchlist undo 3
+*/
+func undoHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "undo", count?}
+	args := parseArgs(m.Content)
 
-	message, ok := messages[command]
+	count := 1
+	if len(args) >= 3 {
+		n, err := strconv.Atoi(args[2])
+		if err != nil {
+			replyError(s, m, UNDO_COMMAND, &InvalidNumberError{"count", args[2]})
+			return
+		}
+		count = n
+	}
+
+	undone, err := Undo(db, messageActor(m), m.Author.ID, count, time.Now())
+	if err != nil {
+		replyError(s, m, UNDO_COMMAND, err)
+		return
+	}
+
+	lines := make([]string, len(undone))
+	for i, j := range undone {
+		lines[i] = fmt.Sprintf("undid %s", j)
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.undoHandler", "user", m.Author.Username, "undone", len(undone))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", strings.Join(lines, "\n")))
+}
+
+/*
+Lists the entries in the user's trash with when they'll be purged
+
+Usage:
+
+	./watchlist trash
+*/
+func trashHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+	trash, err := FetchTrash(db, m.Author.ID)
+	if err != nil {
+		replyError(s, m, TRASH_COMMAND, err)
+		return
+	}
+
+	if len(trash) == 0 {
+		s.ChannelMessageSend(m.ChannelID, "```your trash is empty```")
+		return
+	}
+
+	loc := userLocation(db, m.Author.ID)
+	lines := make([]string, len(trash))
+	for i, e := range trash {
+		lines[i] = fmt.Sprintf("%s (%s) - deleted %s, purged %s",
+			e.DisplayTitle(), e.Category, formatTime(*e.Deleted, loc), formatTime(e.Deleted.Add(TRASH_RETENTION), loc))
+	}
+
+	// Log and send the trash
+	slog.Info("handlers.trashHandler", "user", m.Author.Username, "entries", len(trash))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", strings.Join(lines, "\n")))
+}
+
+/*
+Restores an entry from the trash to its old position in the watchlist, then sends a confirmation message
+
+Usage:
+
+	./watchlist restore <title>
+	./watchlist restore <title> <category>
+
+Example:
+
+	./watchlist restore "The Godfather"
+	./watchlist restore "The Godfather" movie
+*/
+func restoreHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "restore", title, category?}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 3 {
+		replyError(s, m, RESTORE_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title
+
+	var category Category
+	if len(args) >= 4 {
+		category = Category(args[3])
+	}
+
+	entry, err := RestoreEntry(db, messageActor(m), m.Author.ID, args[2], category, year)
+	if err != nil {
+		replyError(s, m, RESTORE_COMMAND, err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.restoreHandler", "user", m.Author.Username, "title", entry.Title, "category", entry.Category)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```restored %s to your watchlist at #%d```", entry.DisplayTitle(), entry.Priority))
+}
+
+/*
+Displays the changes made to an entry, newest first
+
+Usage:
+
+	./watchlist history <title>
+	./watchlist history <title> <category>
+
+Example:
+
+	./watchlist history "The Godfather"
+	./watchlist history "Dune (2021)" movie
+*/
+func historyHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "history", title, category?}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 3 {
+		replyError(s, m, HISTORY_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title
+
+	var category Category
+	if len(args) >= 4 {
+		category = Category(args[3])
+	}
+
+	changes, err := FetchHistory(db, m.Author.ID, args[2], category, year)
+	if err != nil {
+		replyError(s, m, HISTORY_COMMAND, err)
+		return
+	}
+	if len(changes) == 0 {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```no changes to %s```", displayTitle(args[2], year)))
+		return
+	}
+
+	loc := userLocation(db, m.Author.ID)
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		lines[i] = fmt.Sprintf("%s  %s  %s (%s): %s", formatTime(c.Date, loc), actorName(db, &c.Actor), displayTitle(c.Title, c.Year), c.Category, c.Summary())
+	}
+
+	// Log and send the history
+	slog.Info("handlers.historyHandler", "user", m.Author.Username, "title", args[2], "changes", len(changes))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", strings.Join(lines, "\n")))
+}
+
+/*
+Displays the changes made in this server, for server admins
+
+Usage:
+
+	./watchlist audit <@user?> <from:date?> <to:date?>
+
+Example:
+
+	./watchlist audit
+	./watchlist audit @user
+	./watchlist audit from:2024-12-01 to:2024-12-31
+*/
+func auditHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	if m.GuildID == "" {
+		replyError(s, m, AUDIT_COMMAND, &NotInGuildError{AUDIT_COMMAND})
+		return
+	}
+
+	permissions, err := s.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		replyError(s, m, AUDIT_COMMAND, err)
+		return
+	}
+	if permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) == 0 {
+		replyError(s, m, AUDIT_COMMAND, &NotGuildAdminError{m.Author.Username})
+		return
+	}
+
+	// args = []string{"./watchlist", "audit", @user?, from:date?, to:date?}
+	args := parseArgs(m.Content)
+
+	query := &AuditQuery{GuildID: m.GuildID}
+	if len(m.Mentions) > 0 {
+		query.UserID = m.Mentions[0].ID
+	}
+
+	// Dates are whole days in the admin's timezone, "to" includes its day
+	loc := userLocation(db, m.Author.ID)
+	for _, arg := range args[2:] {
+		key, value, found := strings.Cut(strings.ToLower(arg), ":")
+		if !found || (key != "from" && key != "to") {
+			continue
+		}
+
+		day, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			replyError(s, m, AUDIT_COMMAND, &InvalidTimestampError{value})
+			return
+		}
+		if key == "from" {
+			query.From = day
+		} else {
+			query.To = day.AddDate(0, 0, 1)
+		}
+	}
+
+	changes, err := FetchAudit(db, query)
+	if err != nil {
+		replyError(s, m, AUDIT_COMMAND, err)
+		return
+	}
+	if len(changes) == 0 {
+		s.ChannelMessageSend(m.ChannelID, "```no changes match```")
+		return
+	}
+
+	lines := make([]string, len(changes))
+	for i, c := range changes {
+		owner, err := FetchUsername(db, c.UserID)
+		if err != nil {
+			owner = c.UserID
+		}
+		lines[i] = fmt.Sprintf("%s  %s  %s's %s (%s): %s",
+			formatTime(c.Date, loc), actorName(db, &c.Actor), owner, displayTitle(c.Title, c.Year), c.Category, c.Summary())
+	}
+
+	// Log and send the audit log
+	slog.Info("handlers.auditHandler", "user", m.Author.Username, "guild", m.GuildID, "changes", len(changes))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", strings.Join(lines, "\n")))
+}
+
+// Name to show for whoever made a change
+func actorName(db *sql.DB, a *Actor) string {
+	if a.UserID == "" {
+		return string(a.Source)
+	}
+
+	name, err := FetchUsername(db, a.UserID)
+	if err != nil {
+		return a.UserID
+	}
+	return name
+}
+
+/*
+Sets the runtime of an entry, then sends a confirmation message
+
+Usage:
+
+	./watchlist runtime <title> <minutes>
+	./watchlist runtime <title> <category> <minutes>
+
+Example:
+
+	./watchlist runtime "The Godfather" 175
+	./watchlist runtime "The Godfather" movie 175
+*/
+func runtimeHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args1 = []string{"./watchlist", "runtime", title, minutes}
+	// args2 = []string{"./watchlist", "runtime", title, category, minutes}
+	args, year := splitTitleYear(parseArgs(m.Content))
+	if len(args) < 4 {
+		replyError(s, m, RUNTIME_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title and runtime
+
+	title, category, value := parseTarget(args)
+	runtime, err := strconv.Atoi(value)
+	if err != nil || runtime < 0 {
+		replyError(s, m, RUNTIME_COMMAND, &InvalidNumberError{"runtime", value})
+		return
+	}
+
+	// Update database
+	entry, err := RuntimeEntry(db, messageActor(m), m.Author.ID, title, category, year, runtime)
+	if err != nil {
+		replyError(s, m, RUNTIME_COMMAND, err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.runtimeHandler", "user", m.Author.Username, "title", entry.Title, "runtime", runtime)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```set runtime of %s to %d minutes```", entry.DisplayTitle(), runtime))
+}
+
+/*
+Schedules a reminder about an entry (or a random pick), or lists and cancels reminders
+
+Reminders are sent in the current channel, or in DMs if the last argument is "dm".
+A repeat of daily/weekly/monthly makes the reminder recur, without a date the first
+reminder is sent one interval from now.
+
+Usage:
+
+	./watchlist remind <title> <category?> <date?> <daily/weekly/monthly?> <dm?>
+	./watchlist remind random <date?> <daily/weekly/monthly?> <dm?>
+	./watchlist remind list
+	./watchlist remind cancel <id>
+
+Example:
+
+	./watchlist remind "The Godfather" friday 20:00
+	./watchlist remind "The Godfather" movie "in 2 hours" dm
+	./watchlist remind random weekly dm
+	./watchlist remind cancel 3
+*/
+func remindHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "remind", title/"random"/"list"/"cancel", ...}
+	args := parseArgs(m.Content)
+	if len(args) < 3 {
+		replyError(s, m, REMIND_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title or subcommand
+
+	switch args[2] {
+	case "list":
+		reminders, err := FetchReminders(db, m.Author.ID)
+		if err != nil {
+			replyError(s, m, REMIND_COMMAND, err)
+			return
+		}
+
+		message := "```you have no reminders```"
+		if len(reminders) > 0 {
+			loc := userLocation(db, m.Author.ID)
+			message = "Your reminders:\n"
+			for _, r := range reminders {
+				message += r.Format(loc) + "\n"
+			}
+		}
+
+		slog.Info("handlers.remindHandler", "user", m.Author.Username, "reminders", len(reminders))
+		s.ChannelMessageSend(m.ChannelID, message)
+		return
+
+	case "cancel":
+		if len(args) < 4 {
+			replyError(s, m, REMIND_COMMAND, &NotEnoughArgumentsError{m.Content})
+			return
+		}
+
+		reminderID, err := strconv.ParseInt(strings.TrimPrefix(args[3], "#"), 10, 64)
+		if err != nil {
+			replyError(s, m, REMIND_COMMAND, &InvalidNumberError{"reminder", args[3]})
+			return
+		}
+		if err = CancelReminder(db, m.Author.ID, reminderID); err != nil {
+			replyError(s, m, REMIND_COMMAND, err)
+			return
+		}
+
+		slog.Info("handlers.remindHandler", "user", m.Author.Username, "cancelled", reminderID)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```cancelled reminder #%d```", reminderID))
+		return
+	}
+
+	args, year := splitTitleYear(args)
+	reminder := &Reminder{UserID: m.Author.ID, ChannelID: m.ChannelID}
+	rest := args[2:]
+
+	// Optional trailing flags: <repeat?> <dm?>
+	if rest[len(rest)-1] == "dm" {
+		reminder.DM = true
+		rest = rest[:len(rest)-1]
+	}
+	if len(rest) == 0 {
+		replyError(s, m, REMIND_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	}
+	if repeat := Repeat(rest[len(rest)-1]); repeat != REPEAT_NONE && repeat.IsValid() == nil {
+		reminder.Repeat = repeat
+		rest = rest[:len(rest)-1]
+	}
+	if len(rest) == 0 {
+		replyError(s, m, REMIND_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	}
+
+	// <title> <category?> <date...>
+	title := rest[0]
+	rest = rest[1:]
+	var category Category
+	if len(rest) > 0 {
+		if c := Category(rest[0]); c.IsValid() == nil {
+			category = c
+			rest = rest[1:]
+		}
+	}
+
+	// Random pick reminders have no title, entry reminders must point at an entry
+	if title != RANDOM_COMMAND {
+		entry, err := FindEntry(db, messageViewer(m), m.Author.ID, title, category, year)
+		if err != nil {
+			replyError(s, m, REMIND_COMMAND, err)
+			return
+		}
+		reminder.Title = entry.Title
+		reminder.Category = entry.Category
+		reminder.Year = entry.Year
+	}
+
+	// Without a date, repeating reminders start one interval from now
+	now := time.Now()
+	loc := userLocation(db, m.Author.ID)
+	if len(rest) == 0 && reminder.Repeat != REPEAT_NONE {
+		reminder.Due = reminder.Repeat.next(now.In(loc))
+	} else {
+		due, err := ParseWhen(strings.Join(rest, " "), now, loc)
+		if err != nil {
+			replyError(s, m, REMIND_COMMAND, err)
+			return
+		}
+		reminder.Due = due
+	}
+
+	// Save to database
+	if err := reminder.Add(db); err != nil {
+		replyError(s, m, REMIND_COMMAND, err)
+		return
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.remindHandler", "user", m.Author.Username, "reminder", reminder)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```reminder set: %s```", reminder.Format(loc)))
+}
+
+/*
+Schedules a watch party for an entry on the host's watchlist, or lists and cancels parties
+
+The party is posted with going/maybe/not going buttons, attendees are reminded shortly
+before it starts and once it's over the host is offered to mark the entry done for
+everyone who went.
+
+Usage:
+
+	./watchlist party <title> <category?> <date>
+	./watchlist party list
+	./watchlist party cancel <id>
+
+Example:
+
+	./watchlist party "The Godfather" saturday 8pm
+	./watchlist party "The Godfather" movie "2024-12-24 20:00"
+	./watchlist party cancel 2
+*/
+func partyHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "party", title/"list"/"cancel", ...}
+	args := parseArgs(m.Content)
+	if len(args) < 3 {
+		replyError(s, m, PARTY_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	} // Ensure we have at least a title or subcommand
+
+	switch args[2] {
+	case "list":
+		parties, err := FetchUpcomingParties(db, m.GuildID)
+		if err != nil {
+			replyError(s, m, PARTY_COMMAND, err)
+			return
+		}
+
+		message := "```no upcoming watch parties```"
+		if len(parties) > 0 {
+			message = "Upcoming watch parties:\n"
+			for _, p := range parties {
+				message += fmt.Sprintf("#%d **%s** <t:%d:F> hosted by <@%s>\n", p.PartyID, p.DisplayTitle(), p.Start.Unix(), p.HostID)
+			}
+		}
+
+		slog.Info("handlers.partyHandler", "user", m.Author.Username, "parties", len(parties))
+		s.ChannelMessageSend(m.ChannelID, message)
+		return
+
+	case "cancel":
+		if len(args) < 4 {
+			replyError(s, m, PARTY_COMMAND, &NotEnoughArgumentsError{m.Content})
+			return
+		}
+
+		partyID, err := strconv.ParseInt(strings.TrimPrefix(args[3], "#"), 10, 64)
+		if err != nil {
+			replyError(s, m, PARTY_COMMAND, &InvalidNumberError{"watch party", args[3]})
+			return
+		}
+
+		party, err := CancelParty(db, m.Author.ID, partyID)
+		if err != nil {
+			replyError(s, m, PARTY_COMMAND, err)
+			return
+		}
+
+		slog.Info("handlers.partyHandler", "user", m.Author.Username, "cancelled", partyID)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```cancelled watch party #%d for %s```", party.PartyID, party.DisplayTitle()))
+
+		// Take the RSVP buttons off the invite
+		if party.MessageID != "" {
+			s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+				Channel:    party.ChannelID,
+				ID:         party.MessageID,
+				Embeds:     &[]*discordgo.MessageEmbed{party.Embed()},
+				Components: &[]discordgo.MessageComponent{},
+			})
+		}
+		return
+	}
+
+	// <title> <year?> <category?> <date...>
+	args, year := splitTitleYear(args)
+	title := args[2]
+	rest := args[3:]
+	var category Category
+	if len(rest) > 0 {
+		if c := Category(rest[0]); c.IsValid() == nil {
+			category = c
+			rest = rest[1:]
+		}
+	}
+
+	entry, err := FindEntry(db, messageViewer(m), m.Author.ID, title, category, year)
+	if err != nil {
+		replyError(s, m, PARTY_COMMAND, err)
+		return
+	}
+
+	start, err := ParseWhen(strings.Join(rest, " "), time.Now(), userLocation(db, m.Author.ID))
+	if err != nil {
+		replyError(s, m, PARTY_COMMAND, err)
+		return
+	}
+
+	duration := entry.Runtime
+	if duration == 0 {
+		duration = DEFAULT_PARTY_DURATION
+	}
+
+	party := &Party{
+		GuildID:   m.GuildID,
+		ChannelID: m.ChannelID,
+		HostID:    m.Author.ID,
+		Title:     entry.Title,
+		Category:  entry.Category,
+		Year:      entry.Year,
+		Start:     start,
+		Duration:  duration,
+		State:     PARTY_SCHEDULED,
+	}
+
+	// Save to database
+	if err = party.Add(db); err != nil {
+		replyError(s, m, PARTY_COMMAND, err)
+		return
+	}
+
+	// Log and send the invite with RSVP buttons
+	slog.Info("handlers.partyHandler", "user", m.Author.Username, "party", party)
+	invite, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{party.Embed()},
+		Components: party.Components(),
+	})
+	if err != nil {
+		slog.Error("handlers.partyHandler", "party", party.PartyID, "msg", err)
+		return
+	}
+
+	// Keep the invite so cancelling the party can take its buttons off
+	if err = party.SetMessage(db, invite.ID); err != nil {
+		slog.Error("handlers.partyHandler", "party", party.PartyID, "msg", err)
+	}
+}
+
+/*
+Displays or sets the timezone used to read and show your dates
+
+Usage:
+
+	./watchlist timezone
+	./watchlist timezone <timezone>
+
+Example:
+
+	./watchlist timezone America/Edmonton
+*/
+func timezoneHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "timezone", timezone?}
+	args := parseArgs(m.Content)
+
+	user, err := FetchUser(db, m.Author.ID)
+	if err != nil {
+		replyError(s, m, TIMEZONE_COMMAND, err)
+		return
+	}
+
+	// case: ./watchlist timezone <timezone>
+	if len(args) >= 3 {
+		if err = user.SetTimezone(db, args[2]); err != nil {
+			replyError(s, m, TIMEZONE_COMMAND, err)
+			return
+		}
+	}
+
+	timezone := user.Timezone
+	if timezone == "" {
+		timezone = "not set, using the server's timezone"
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.timezoneHandler", "user", m.Author.Username, "timezone", user.Timezone)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```timezone: %s\nyour time: %s```", timezone, formatTime(time.Now(), user.Location())))
+}
+
+/*
+Displays or sets who can see your watchlist
+
+	private	only you
+	guild	members of servers you've used the bot in (compare, leaderboards, server recaps and links)
+	link	guild, plus anyone with your share link
+
+Usage:
+
+	./watchlist privacy
+	./watchlist privacy <private/guild/link>
+
+Example:
+
+	./watchlist privacy private
+*/
+func privacyHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "privacy", privacy?}
+	args := parseArgs(m.Content)
+
+	user, err := FetchUser(db, m.Author.ID)
+	if err != nil {
+		replyError(s, m, PRIVACY_COMMAND, err)
+		return
+	}
+
+	// case: ./watchlist privacy <privacy>
+	if len(args) >= 3 {
+		if err = user.SetPrivacy(db, Privacy(strings.ToLower(args[2]))); err != nil {
+			replyError(s, m, PRIVACY_COMMAND, err)
+			return
+		}
+	}
+
+	descriptions := map[Privacy]string{
+		PRIVACY_PRIVATE: "only you can see your watchlist",
+		PRIVACY_GUILD:   "members of servers you use the bot in can see your watchlist, share links to it don't work",
+		PRIVACY_LINK:    "members of servers you use the bot in and anyone with your share link can see your watchlist",
+	}
+
+	// Log and send a confirmation message
+	slog.Info("handlers.privacyHandler", "user", m.Author.Username, "privacy", user.Privacy)
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```privacy: %s\n%s```", user.Privacy, descriptions[user.Privacy]))
+}
+
+/*
+Sends the user a new personal API token in their DMs, or revokes their token
+
+Generating a new token revokes the previous one.
+
+Usage:
+
+	./watchlist token
+	./watchlist token revoke
+*/
+func tokenHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "token", "revoke"?}
+	args := parseArgs(m.Content)
+	user := &User{UserID: m.Author.ID}
+
+	// case: ./watchlist token revoke
+	if len(args) >= 3 && args[2] == "revoke" {
+		if err := user.RevokeAPIToken(db); err != nil {
+			replyError(s, m, TOKEN_COMMAND, err)
+			return
+		}
+
+		slog.Info("handlers.tokenHandler", "user", m.Author.Username, "revoked", true)
+		s.ChannelMessageSend(m.ChannelID, "```revoked your API token```")
+		return
+	}
+
+	// Tokens are only ever sent in DMs
+	channel, err := s.UserChannelCreate(m.Author.ID)
+	if err != nil {
+		slog.Error("handlers.tokenHandler", "msg", err)
+		replyError(s, m, TOKEN_COMMAND, &CannotDMError{m.Author.Username})
+		return
+	}
+
+	token, err := user.NewAPIToken(db)
+	if err != nil {
+		replyError(s, m, TOKEN_COMMAND, err)
+		return
+	}
+
+	// Log and send the token
+	slog.Info("handlers.tokenHandler", "user", m.Author.Username)
+	s.ChannelMessageSend(channel.ID, fmt.Sprintf("Your API token (keep it secret, any previous token no longer works):\n```%s```", token))
+	if channel.ID != m.ChannelID {
+		s.ChannelMessageSend(m.ChannelID, "```sent you a new API token in your DMs```")
+	}
+}
+
+/*
+Sends a read-only web link to your watchlist (or this server's), or revokes it
+
+Usage:
+
+	./watchlist share
+	./watchlist share server
+	./watchlist share revoke
+	./watchlist share server revoke
+*/
+func shareHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "share", "server"?, "revoke"?}
+	args := parseArgs(m.Content)
+
+	var guildID string
+	what, revoke := "your watchlist", "./watchlist share revoke"
+	if len(args) >= 3 && args[2] == "server" {
+		if m.GuildID == "" {
+			replyError(s, m, SHARE_COMMAND, &NotInGuildError{SHARE_COMMAND + " server"})
+			return
+		}
+		guildID = m.GuildID
+		what, revoke = "this server's watchlist", "./watchlist share server revoke"
+	}
+
+	// case: ./watchlist share <server?> revoke
+	if args[len(args)-1] == "revoke" {
+		if err := RevokeShare(db, m.Author.ID, guildID); err != nil {
+			replyError(s, m, SHARE_COMMAND, err)
+			return
+		}
+
+		slog.Info("handlers.shareHandler", "user", m.Author.Username, "guild", guildID, "revoked", true)
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```revoked your link to %s```", what))
+		return
+	}
+
+	share, err := FetchOrCreateShare(db, m.Author.ID, guildID)
+	if err != nil {
+		replyError(s, m, SHARE_COMMAND, err)
+		return
+	}
+
+	message := fmt.Sprintf("Read-only link to %s (revoke it with `%s`):\n%s", what, revoke, share.URL())
+	if guildID == "" {
+		user, err := FetchUser(db, m.Author.ID)
+		if err != nil {
+			replyError(s, m, SHARE_COMMAND, err)
+			return
+		}
+		if !user.Privacy.allows(PRIVACY_LINK) {
+			message += fmt.Sprintf("\n```your privacy is set to %s, the link won't work until you run ./watchlist privacy link```", user.Privacy)
+		}
+	} else {
+		message += "\n```only members whose privacy is set to link are shown```"
+	}
+
+	// Log and send the link
+	slog.Info("handlers.shareHandler", "user", m.Author.Username, "guild", guildID)
+	s.ChannelMessageSend(m.ChannelID, message)
+}
+
+/*
+Displays statistics about your watchlist
+
+Usage:
+
+	./watchlist stats
+*/
+func statsHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// Fetch watchlist (including watched items)
+	watchlist, err := FetchWatchlist(db, messageViewer(m), m.Author.ID, true)
+	if err != nil {
+		replyError(s, m, STATS_COMMAND, err)
+		return
+	}
+
+	// Months and dates are shown in the user's timezone
+	now := time.Now().In(userLocation(db, m.Author.ID))
+	stats := ComputeStats(watchlist.Entries, now.Location())
+
+	embed := stats.Embed(fmt.Sprintf("Stats for %s", m.Author.Username), now)
+	embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+		URL: m.Author.AvatarURL(""), // empty string for default avatar size
+	}
+
+	// Log and send stats as an embedded message
+	slog.Info("handlers.statsHandler", "user", m.Author.Username, "total", stats.Total)
+	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+/*
+Displays this server's leaderboards, counting every member that has used the bot here
+
+Usage:
+
+	./watchlist leaderboard
+	./watchlist leaderboard <minimum votes>
+
+Example:
+
+	./watchlist leaderboard 3
+*/
+func leaderboardHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "leaderboard", minVotes?}
+	args := parseArgs(m.Content)
+
+	if m.GuildID == "" {
+		replyError(s, m, LEADERBOARD_COMMAND, &NotInGuildError{LEADERBOARD_COMMAND})
+		return
+	}
+
+	minVotes := DEFAULT_MIN_VOTES
+	if len(args) >= 3 {
+		votes, err := strconv.Atoi(args[2])
+		if err != nil || votes < 1 {
+			replyError(s, m, LEADERBOARD_COMMAND, &InvalidNumberError{"minimum votes", args[2]})
+			return
+		}
+		minVotes = votes
+	}
+
+	board, err := FetchLeaderboard(db, m.GuildID, minVotes)
+	if err != nil {
+		replyError(s, m, LEADERBOARD_COMMAND, err)
+		return
+	}
+
+	embed := board.Embed("Server leaderboards")
+	embed.Footer = &discordgo.MessageEmbedFooter{
+		Text: fmt.Sprintf("%d members, top rated needs at least %d ratings", board.Members, minVotes),
+	}
+
+	// Log and send leaderboards as an embedded message
+	slog.Info("handlers.leaderboardHandler", "user", m.Author.Username, "guild", m.GuildID)
+	s.ChannelMessageSendEmbed(m.ChannelID, embed)
+}
+
+/*
+Displays a summary of everything you (or this server) completed in a year, with a Markdown report attached
+
+Usage:
+
+	./watchlist recap
+	./watchlist recap <year>
+	./watchlist recap <year> server
+
+Example:
+
+	./watchlist recap 2024
+	./watchlist recap 2024 server
+*/
+func recapHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "recap", year?, "server"?}
+	args := parseArgs(m.Content)
+
+	// Years are counted in the caller's timezone, defaulting to the current one
+	now := time.Now().In(userLocation(db, m.Author.ID))
+	year := now.Year()
+	if len(args) >= 3 && args[2] != "server" {
+		y, err := strconv.Atoi(args[2])
+		if err != nil {
+			replyError(s, m, RECAP_COMMAND, &InvalidNumberError{"year", args[2]})
+			return
+		}
+		year = y
+	}
+
+	var (
+		watchlist *Watchlist
+		usernames map[string]string
+		who       = m.Author.Username
+		err       error
+	)
+	if args[len(args)-1] == "server" {
+		if m.GuildID == "" {
+			replyError(s, m, RECAP_COMMAND, &NotInGuildError{RECAP_COMMAND + " server"})
+			return
+		}
+
+		watchlist, err = FetchGuildWatchlist(db, m.GuildID, PRIVACY_GUILD, true)
+		if err != nil {
+			replyError(s, m, RECAP_COMMAND, err)
+			return
+		}
+
+		members, err := FetchMembers(db, m.GuildID)
+		if err != nil {
+			replyError(s, m, RECAP_COMMAND, err)
+			return
+		}
+		usernames = make(map[string]string)
+		for _, member := range members {
+			usernames[member.UserID] = member.Username
+		}
+		who = "this server"
+	} else {
+		watchlist, err = FetchWatchlist(db, messageViewer(m), m.Author.ID, true)
+		if err != nil {
+			replyError(s, m, RECAP_COMMAND, err)
+			return
+		}
+	}
+
+	recap := ComputeRecap(watchlist.Entries, year, now.Location())
+	if recap.Total == 0 {
+		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```nothing completed by %s in %d```", who, year))
+		return
+	}
+
+	title := fmt.Sprintf("%d recap for %s", year, who)
+	report := recap.Markdown(title, usernames)
+
+	// Log and send the recap as an embedded message with the report attached
+	slog.Info("handlers.recapHandler", "user", m.Author.Username, "year", year, "guild", usernames != nil)
+	s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{recap.Embed(title)},
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("recap-%d.md", year),
+			ContentType: "text/markdown",
+			Reader:      strings.NewReader(report),
+		}},
+	})
+}
+
+/*
+Compares your watchlist with another member's
+
+Only members that have used the bot in this server and haven't made their watchlist private can be compared with.
+
+Usage:
+
+	./watchlist compare <@user>
+
+Example:
+
+	./watchlist compare @tem
+*/
+func compareHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	if m.GuildID == "" {
+		replyError(s, m, COMPARE_COMMAND, &NotInGuildError{COMPARE_COMMAND})
+		return
+	}
+	if len(m.Mentions) == 0 || m.Mentions[0].ID == m.Author.ID {
+		replyError(s, m, COMPARE_COMMAND, &NotEnoughArgumentsError{m.Content})
+		return
+	}
+	other := m.Mentions[0]
+
+	// Fetch both watchlists (including watched items)
+	yours, err := FetchWatchlist(db, messageViewer(m), m.Author.ID, true)
+	if err != nil {
+		replyError(s, m, COMPARE_COMMAND, err)
+		return
+	}
+
+	theirs, err := FetchWatchlist(db, messageViewer(m), other.ID, true)
+	if err != nil {
+		replyError(s, m, COMPARE_COMMAND, err)
+		return
+	}
+
+	// Log and send the comparison as an embedded message
+	slog.Info("handlers.compareHandler", "user", m.Author.Username, "other", other.Username)
+	s.ChannelMessageSendEmbed(m.ChannelID, CompareWatchlists(yours, theirs).Embed(m.Author.Username, other.Username))
+}
+
+/*
+Recommends titles other members of this server rated highly that you haven't added, based on your ratings
+
+Usage:
+
+	./watchlist recommend
+	./watchlist recommend <category>
+
+Example:
+
+	./watchlist recommend anime
+*/
+func recommendHandler(db *sql.DB, s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "recommend", category?}
+	args := parseArgs(m.Content)
+
+	if m.GuildID == "" {
+		replyError(s, m, RECOMMEND_COMMAND, &NotInGuildError{RECOMMEND_COMMAND})
+		return
+	}
+
+	var category Category
+	if len(args) >= 3 {
+		category = Category(strings.ToLower(args[2]))
+		if err := category.IsValid(); err != nil {
+			replyError(s, m, RECOMMEND_COMMAND, err)
+			return
+		}
+	}
+
+	recommendations, err := Recommend(db, m.Author.ID, m.GuildID, category, DEFAULT_RECOMMEND_COUNT)
+	if err != nil {
+		replyError(s, m, RECOMMEND_COMMAND, err)
+		return
+	}
+	if len(recommendations) == 0 {
+		s.ChannelMessageSend(m.ChannelID, "```nothing to recommend yet, rate more of what you've watched```")
+		return
+	}
+
+	var lines []string
+	for i, r := range recommendations {
+		lines = append(lines, fmt.Sprintf("%d. %s (%s) - %s", i+1, displayTitle(r.Title, r.Year), r.Category, r.Reason()))
+	}
+
+	// Log and send the recommendations
+	slog.Info("handlers.recommendHandler", "user", m.Author.Username, "count", len(recommendations))
+	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("```%s```", strings.Join(lines, "\n")))
+}
+
+// Usage of each command, shown by the help command and with errors a command's usage would help fix
+var helpMessages = map[string]string{
+	ADD_COMMAND:         "Adding a movie to your watchlist (you're asked first if it looks like one that's already on it):\n```./watchlist add <title> <year(optional)> <category> <position(optional)> <link(optional)>\n./watchlist add \"Dune (2021)\" movie\n./watchlist add \"Dune\" 2021 movie```\nThe year goes in the title or before the category, a number after the category is a position",
+	DELETE_COMMAND:      "Deleting a movie from your watchlist (it stays in the trash until it's purged):\n```./watchlist delete <title>\n./watchlist delete <title> <category>```",
+	VIEW_COMMAND:        "Viewing your watchlist:\n```./watchlist view\n./watchlist view title\n./watchlist view category\n./watchlist view date\n./watchlist view priority\n./watchlist view on:<service>\n./watchlist view on:<service>:<region>```",
+	INFO_COMMAND:        "Viewing all the details of a movie in your watchlist:\n```./watchlist info <title>\n./watchlist info <title> <category>```",
+	UPDATE_COMMAND:      "Updating a movie in your watchlist:\n```./watchlist update <title> <new_link>```",
+	DONE_COMMAND:        "Marking a movie as completed, again to log a rewatch:\n```./watchlist done <title>\n./watchlist done <title> <category>\n./watchlist done <title> <category> <note>```",
+	RATE_COMMAND:        "Rating a movie in your watchlist from 1 to 10:\n```./watchlist rate <title> <rating>\n./watchlist rate <title> <category> <rating>```",
+	MOVE_COMMAND:        "Moving a movie up or down your watchlist:\n```./watchlist move <title> <up/down/top/bottom/position>\n./watchlist move <title> <category> <up/down/top/bottom/position>```",
+	TAG_COMMAND:         "Tagging a movie in your watchlist:\n```./watchlist tag <title> <tag>\n./watchlist tag <title> <category> <tag>```",
+	UNTAG_COMMAND:       "Removing a tag from a movie in your watchlist:\n```./watchlist untag <title> <tag>\n./watchlist untag <title> <category> <tag>```",
+	LINK_COMMAND:        "Adding a link (trailer/info/streaming/other) to a movie in your watchlist:\n```./watchlist link <title> <link> <kind(optional)>\n./watchlist link <title> <category> <link> <kind(optional)>```",
+	UNLINK_COMMAND:      "Removing a link, or every link of a kind, from a movie in your watchlist:\n```./watchlist unlink <title> <link/kind>\n./watchlist unlink <title> <category> <link/kind>```",
+	STREAM_COMMAND:      "Viewing, noting or looking up where a movie in your watchlist is streaming:\n```./watchlist stream <title>\n./watchlist stream <title> <category(optional)> <service> <free/rent/buy(optional)> <region(optional)>\n./watchlist stream <title> <category(optional)> lookup <region(optional)>```",
+	UNSTREAM_COMMAND:    "Removing a streaming service from a movie in your watchlist:\n```./watchlist unstream <title> <category(optional)> <service> <region(optional)>```",
+	UNDO_COMMAND:        "Undoing your last delete, done, rate or update (from the last 24 hours):\n```./watchlist undo\n./watchlist undo <count>```",
+	TRASH_COMMAND:       "Listing the movies you deleted, they're purged after a while:\n```./watchlist trash```",
+	RESTORE_COMMAND:     "Restoring a deleted movie to your watchlist:\n```./watchlist restore <title>\n./watchlist restore <title> <category>```",
+	HISTORY_COMMAND:     "Viewing the changes made to a movie in your watchlist, even after it's deleted:\n```./watchlist history <title>\n./watchlist history <title> <category>```",
+	AUDIT_COMMAND:       "Viewing the changes made in this server, optionally by or to one member and between two dates (server admins only):\n```./watchlist audit\n./watchlist audit <@user> from:<YYYY-MM-DD> to:<YYYY-MM-DD>```",
+	RUNTIME_COMMAND:     "Setting the runtime (in minutes) of a movie in your watchlist:\n```./watchlist runtime <title> <minutes>\n./watchlist runtime <title> <category> <minutes>```",
+	RANDOM_COMMAND:      "Getting random movies from your watchlist:\n```./watchlist random\n./watchlist random top\n./watchlist random <category> tag:<tag> runtime:<minutes> on:<service(:region)> weight:<none/age/priority> count:<n> skip:<n>```",
+	REMIND_COMMAND:      "Setting, listing and cancelling reminders:\n```./watchlist remind <title> <category?> <date> <daily/weekly/monthly?> <dm?>\n./watchlist remind random <date?> <daily/weekly/monthly?> <dm?>\n./watchlist remind list\n./watchlist remind cancel <id>```",
+	PARTY_COMMAND:       "Scheduling, listing and cancelling watch parties:\n```./watchlist party <title> <category?> <date>\n./watchlist party list\n./watchlist party cancel <id>```",
+	TIMEZONE_COMMAND:    "Viewing or setting your timezone:\n```./watchlist timezone\n./watchlist timezone <timezone (ex. America/Edmonton)>```",
+	PRIVACY_COMMAND:     "Viewing or setting who can see your watchlist (private: only you, guild: members of servers you use the bot in, link: guild plus anyone with your share link):\n```./watchlist privacy\n./watchlist privacy <private/guild/link>```",
+	TOKEN_COMMAND:       "Getting (in your DMs) or revoking a personal API token:\n```./watchlist token\n./watchlist token revoke```",
+	SHARE_COMMAND:       "Getting or revoking a read-only web link to your (or this server's) watchlist:\n```./watchlist share\n./watchlist share server\n./watchlist share revoke\n./watchlist share server revoke```",
+	STATS_COMMAND:       "Viewing statistics about your watchlist:\n```./watchlist stats```",
+	LEADERBOARD_COMMAND: "Viewing this server's leaderboards (titles need a minimum number of ratings to be top rated, 2 by default):\n```./watchlist leaderboard\n./watchlist leaderboard <minimum votes>```",
+	RECAP_COMMAND:       "Viewing everything you (or this server) completed in a year, with a Markdown report attached:\n```./watchlist recap\n./watchlist recap <year>\n./watchlist recap <year> server```",
+	COMPARE_COMMAND:     "Comparing your watchlist with another member of this server:\n```./watchlist compare <@user>```",
+	RECOMMEND_COMMAND:   "Getting titles this server rated highly that you haven't added, based on your ratings:\n```./watchlist recommend\n./watchlist recommend <category>```",
+	HELP_COMMAND:        "Displaying this help message:\n```./watchlist help\n./watchlist help <command>```",
+	CONTACT_COMMAND:     "Get contact info for the developer:\n```./watchlist contact```",
+}
+
+/*
+Displays the help message
+
+Usage:
+
+	./watchlist help
+	./watchlist help <command>
+
+Example:
+
+	./watchlist help
+	./watchlist help add
+*/
+func helpHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
+
+	// args = []string{"./watchlist", "help", command}
+	args := parseArgs(m.Content)
+
+	var command string
+	if len(args) >= 3 {
+		command = args[2]
+	}
 
-	// If we get no command or an invalid command, show all help tips
+	// If we get no command or an invalid command, list every command
+	message, ok := helpMessages[command]
 	if !ok {
-		for _, message := range messages {
-			message += "\n"
+		commands := make([]string, 0, len(helpMessages))
+		for command := range helpMessages {
+			commands = append(commands, command)
 		}
+		sort.Strings(commands)
+		message = fmt.Sprintf("Commands (see ./watchlist help <command> for how to use one):\n```%s```", strings.Join(commands, ", "))
 	}
 
 	slog.Info("handlers.HelpHandler", "user", m.Author.Username)
@@ -0,0 +1,205 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+Main handler for button presses and other message components
+
+Components are routed on the prefix of their custom ID (ex. party:12:going),
+the remaining parts are passed on as arguments.
+
+All component handler functions require use the following parameters:
+  - db:		ptr to database connection
+  - s:		ptr to discord session
+  - i:		ptr to discord interaction (contains info about the user, message, etc.)
+  - args:	parts of the custom ID after the prefix
+*/
+func InteractionHandler(db *sql.DB, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	// args = []string{prefix, arg1, arg2, ...}
+	args := strings.Split(i.MessageComponentData().CustomID, ":")
+
+	switch args[0] {
+	case PARTY_BUTTON_PREFIX:
+		partyButtonHandler(db, s, i, args[1:])
+	case DUPLICATE_BUTTON_PREFIX:
+		duplicateButtonHandler(db, s, i, args[1:])
+	default:
+		slog.Warn("interactions.InteractionHandler", "msg", "unknown component", "custom_id", i.MessageComponentData().CustomID)
+	}
+}
+
+/*
+Records an RSVP, or marks the party's entry as done for attendees
+
+Custom ID:
+
+	party:<partyID>:<going/maybe/not_going/done>
+*/
+func partyButtonHandler(db *sql.DB, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+	if len(args) < 2 {
+		respondError(s, i, &NotEnoughArgumentsError{strings.Join(args, ":")})
+		return
+	}
+
+	partyID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		respondError(s, i, &InvalidNumberError{"watch party", args[0]})
+		return
+	}
+	user := interactionUser(i)
+
+	// Host confirms the party is over
+	if args[1] == "done" {
+		party, marked, err := FinishParty(db, interactionActor(i), partyID)
+		if err != nil {
+			respondError(s, i, err)
+			return
+		}
+
+		slog.Info("interactions.partyButtonHandler", "user", user.Username, "party", partyID, "marked", marked)
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    fmt.Sprintf("marked **%s** as done for %s", party.DisplayTitle(), mentionAll(marked)),
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		return
+	}
+
+	// Anyone else is responding to the invite
+	party, err := SetRSVP(db, partyID, user.ID, RSVP(args[1]))
+	if err != nil {
+		respondError(s, i, err)
+		return
+	}
+
+	slog.Info("interactions.partyButtonHandler", "user", user.Username, "party", partyID, "rsvp", args[1])
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{party.Embed()},
+			Components: party.Components(),
+		},
+	})
+}
+
+/*
+Answers a duplicate prompt from the add command, only the user that made the add can answer it
+
+Custom ID:
+
+	dupe:<pendingID>:<keep/link/cancel>
+*/
+func duplicateButtonHandler(db *sql.DB, s *discordgo.Session, i *discordgo.InteractionCreate, args []string) {
+	if len(args) < 2 {
+		respondError(s, i, &NotEnoughArgumentsError{strings.Join(args, ":")})
+		return
+	}
+
+	pendingID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		respondError(s, i, &InvalidNumberError{"duplicate prompt", args[0]})
+		return
+	}
+	user := interactionUser(i)
+
+	pending, err := FetchPendingAdd(db, pendingID, user.ID)
+	if err != nil {
+		respondError(s, i, err)
+		return
+	}
+
+	var message string
+	switch args[1] {
+	case DUPLICATE_KEEP:
+		if err = pending.Entry.Add(db, interactionActor(i)); err == nil {
+			message = fmt.Sprintf("added %s to your watchlist at #%d", pending.Entry.DisplayTitle(), pending.Entry.Priority)
+		}
+	case DUPLICATE_LINK:
+		var (
+			entry *Entry
+			link  *Link
+		)
+		entry, link, err = UpdateEntry(db, interactionActor(i), user.ID, pending.Title, pending.Category, pending.Year, pending.Entry.Link)
+		if err == nil {
+			message = fmt.Sprintf("updated %s %s -> %s", entry.DisplayTitle(), link.Kind, link.URL)
+		}
+	default:
+		message = fmt.Sprintf("didn't add %s", pending.Entry.DisplayTitle())
+	}
+	if err != nil {
+		respondError(s, i, err)
+		return
+	}
+
+	// The prompt is answered either way
+	if err = pending.Delete(db); err != nil {
+		slog.Error("interactions.duplicateButtonHandler", "msg", err)
+	}
+
+	slog.Info("interactions.duplicateButtonHandler", "user", user.Username, "pending", pendingID, "action", args[1])
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("```%s```", message),
+			Embeds:     []*discordgo.MessageEmbed{},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+}
+
+// User that triggered an interaction (Member is only set in guilds, User only in DMs)
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Actor for changes made through an interaction
+func interactionActor(i *discordgo.InteractionCreate) *Actor {
+	return &Actor{UserID: interactionUser(i).ID, Source: SOURCE_DISCORD, GuildID: i.GuildID, ChannelID: i.ChannelID}
+}
+
+// Reply to an interaction with a message only the user can see
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
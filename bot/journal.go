@@ -0,0 +1,217 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Actions that can be undone are ACTION_DELETE, ACTION_DONE, ACTION_RATE and ACTION_UPDATE, see audit.go
+const (
+	// How long after an action it can still be undone
+	UNDO_WINDOW = 24 * time.Hour
+
+	// Most actions a single undo reverts
+	MAX_UNDO = 10
+)
+
+// JournalEntry is an action with the entry as it was before the action
+type JournalEntry struct {
+	ActionID int64     `json:"action_id"`
+	UserID   string    `json:"user_id"`
+	Action   Action    `json:"action"`
+	Date     time.Time `json:"date"`
+	Before   *Entry    `json:"before"`
+}
+
+/*
+Record an action in the journal so it can be undone
+
+Also prunes the user's actions that are too old to undo.
+
+Params:
+
+	tx:			transaction the action is done in
+	action:		action that is about to be done
+	before:		the entry before the action, with its tags, links and availability
+*/
+func recordAction(tx *sql.Tx, action Action, before *Entry) error {
+	data, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO journal(userID, action, date, before) VALUES(?, ?, ?, ?)", before.UserID, action, now, string(data))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM journal WHERE userID = ? AND date < ?", before.UserID, now.Add(-UNDO_WINDOW))
+	return err
+}
+
+/*
+Undo a user's most recent actions, newest first
+
+Deleted entries come back from the trash, or are inserted again if they were already purged.
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	actor:	who is undoing the actions
+	userID:	user ID to undo actions for
+	n:		number of actions to undo, between 1 and MAX_UNDO
+	now:	current time, actions older than UNDO_WINDOW can't be undone
+
+Returns:
+
+	[]*JournalEntry:	the undone actions, newest first
+	error:				NothingToUndoError, or EntryNotFoundError/sqlite error if an entry changed since
+*/
+func Undo(db *sql.DB, actor *Actor, userID string, n int, now time.Time) ([]*JournalEntry, error) {
+	n = max(1, min(n, MAX_UNDO))
+
+	query := "SELECT actionID, userID, action, date, before FROM journal WHERE userID = ? AND undone = 0 AND date >= ? ORDER BY actionID DESC LIMIT ?"
+	rows, err := db.Query(query, userID, now.Add(-UNDO_WINDOW).UTC(), n)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []*JournalEntry
+	for rows.Next() {
+		var (
+			j      JournalEntry
+			before string
+		)
+		if err := rows.Scan(&j.ActionID, &j.UserID, &j.Action, &j.Date, &before); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(before), &j.Before); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		actions = append(actions, &j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, &NothingToUndoError{userID}
+	}
+
+	// The audit log gets one change per entry, from before its first action is undone to after its last
+	var (
+		entries []*Entry                    // entries the actions were on, once each
+		current = make(map[entryKey]*Entry) // their state before the undo, by key
+	)
+	for _, j := range actions {
+		key := j.Before.mapKey()
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		e, err := reloadEntry(db, j.Before)
+		if err != nil {
+			return nil, err
+		}
+		current[key] = e
+		entries = append(entries, j.Before)
+	}
+
+	// All or nothing, so a failed undo can be retried
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, j := range actions {
+		if err := j.revert(tx); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE journal SET undone = 1 WHERE actionID = ?", j.ActionID); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range entries {
+		after, err := reloadEntry(tx, e)
+		if err != nil {
+			return nil, err
+		}
+		if err = writeChange(tx, actor, ACTION_UNDO, current[e.mapKey()], after); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("journal.Undo", "user", userID, "actions", len(actions))
+	return actions, nil
+}
+
+// Put the entry back the way it was before the action
+func (j *JournalEntry) revert(tx *sql.Tx) error {
+	e := j.Before
+	if j.Action == ACTION_DELETE {
+		return restore(tx, e)
+	}
+
+	var doneDate any
+	if e.DoneDate != nil {
+		doneDate = e.DoneDate.UTC()
+	}
+
+	query := "UPDATE entries SET done = ?, doneDate = ?, rating = ? WHERE " + ENTRY_KEY
+	result, err := tx.Exec(query, append([]any{e.Done, doneDate, e.Rating}, e.key()...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &EntryNotFoundError{e.UserID, e.DisplayTitle(), e.Category}
+	}
+
+	switch j.Action {
+	case ACTION_DONE:
+		return e.unwatch(tx)
+	case ACTION_RATE:
+		return e.rewriteWatchRating(tx)
+	case ACTION_UPDATE:
+		// Only updates change links, links added since other actions are kept
+		return e.writeLinks(tx)
+	}
+	return nil
+}
+
+// Describe an action, ex. "delete Dune (2021)"
+func (j *JournalEntry) String() string {
+	return fmt.Sprintf("%s %s", j.Action, j.Before.DisplayTitle())
+}
@@ -0,0 +1,158 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Find an entry with its details, failing the test if it's missing
+func mustFindEntry(t *testing.T, db *sql.DB, userID string, title string, category Category) *Entry {
+	t.Helper()
+
+	entry, err := findEntryWithDetails(db, userID, title, category, 0)
+	if err != nil {
+		t.Fatalf("find %s: %v", title, err)
+	}
+	return entry
+}
+
+func TestUndo(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Dune", Category: Movie, Year: 2021},
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "2", Title: "Alien", Category: Movie},
+	)
+	if _, err := TagEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, "sci-fi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LinkEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, "https://www.imdb.com/title/tt1160419/", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// One of each action, plus another user's action that must not be undone
+	if _, err := DoneEntry(db, TEST_ACTOR, "1", "Alien", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := RateEntry(db, TEST_ACTOR, "1", "Alien", Movie, 0, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := UpdateEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, "https://www.imdb.com/title/tt0087182/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DoneEntry(db, TEST_ACTOR, "2", "Alien", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Newest first: the delete and the update
+	undone, err := Undo(db, TEST_ACTOR, "1", 2, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 2 || undone[0].String() != "delete Dune (2021)" || undone[1].String() != "update Dune (2021)" {
+		t.Fatalf("undid %v, want the delete then the update of Dune", undone)
+	}
+
+	dune := mustFindEntry(t, db, "1", "Dune", Movie)
+	if dune.Year != 2021 || !dune.HasTag("sci-fi") || dune.Link != "https://www.imdb.com/title/tt1160419/" {
+		t.Errorf("got Dune (%d) with tags %v and link %q, want it back as before the update", dune.Year, dune.Tags, dune.Link)
+	}
+
+	// Then the rating and completion, everything else is left alone
+	if undone, err = Undo(db, TEST_ACTOR, "1", MAX_UNDO, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 2 || undone[0].Action != ACTION_RATE || undone[1].Action != ACTION_DONE {
+		t.Fatalf("undid %v, want the rating then the completion of Alien", undone)
+	}
+	if alien := mustFindEntry(t, db, "1", "Alien", Movie); alien.Done || alien.DoneDate != nil || alien.Rating != 0 {
+		t.Errorf("got Alien done %v on %v rated %d, want it unwatched", alien.Done, alien.DoneDate, alien.Rating)
+	}
+	if alien := mustFindEntry(t, db, "2", "Alien", Movie); !alien.Done {
+		t.Error("another user's completion was undone")
+	}
+
+	// Undone actions can't be undone twice
+	var nothing *NothingToUndoError
+	if _, err = Undo(db, TEST_ACTOR, "1", 1, time.Now()); !errors.As(err, &nothing) {
+		t.Errorf("third undo = %v, want NothingToUndoError", err)
+	}
+}
+
+func TestUndoWindow(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db, &Entry{UserID: "1", Title: "Dune", Category: Movie})
+	if _, err := DoneEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var nothing *NothingToUndoError
+	if _, err := Undo(db, TEST_ACTOR, "1", 1, time.Now().Add(UNDO_WINDOW+time.Minute)); !errors.As(err, &nothing) {
+		t.Errorf("undo after the window = %v, want NothingToUndoError", err)
+	}
+	if _, err := Undo(db, TEST_ACTOR, "1", 1, time.Now()); err != nil {
+		t.Errorf("undo within the window: %v", err)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+	)
+	if err := RateEntry(db, TEST_ACTOR, "1", "Alien", Movie, 0, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DoneEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alien is gone for good since it was rated, so the undo fails as a whole
+	if _, err := db.Exec("DELETE FROM entries WHERE userID = '1' AND title = 'Alien'"); err != nil {
+		t.Fatal(err)
+	}
+	var notFound *EntryNotFoundError
+	if _, err := Undo(db, TEST_ACTOR, "1", 2, time.Now()); !errors.As(err, &notFound) {
+		t.Fatalf("undo of a rating on a missing entry = %v, want EntryNotFoundError", err)
+	}
+	if dune := mustFindEntry(t, db, "1", "Dune", Movie); !dune.Done {
+		t.Error("a failed undo still reverted the completion")
+	}
+
+	// Nothing was marked undone, so the actions can be retried once the entry is back
+	addTestEntries(t, db, &Entry{UserID: "1", Title: "Alien", Category: Movie, Rating: 3})
+	undone, err := Undo(db, TEST_ACTOR, "1", 2, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 2 {
+		t.Errorf("undid %d actions, want 2", len(undone))
+	}
+	if alien := mustFindEntry(t, db, "1", "Alien", Movie); alien.Rating != 0 {
+		t.Errorf("got Alien rated %d, want the rating undone", alien.Rating)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// Number of rows shown on each leaderboard
+	LEADERBOARD_SIZE = 10
+
+	// Titles need at least this many ratings to show up on the top rated board
+	DEFAULT_MIN_VOTES = 2
+)
+
+// TitleCount is a title with the number of members it applies to
+type TitleCount struct {
+	Title    string   `json:"title"`
+	Category Category `json:"category"`
+	Year     int      `json:"year,omitempty"`
+	Count    int      `json:"count"`
+}
+
+// TitleRating is a title with its average rating across members
+type TitleRating struct {
+	Title    string   `json:"title"`
+	Category Category `json:"category"`
+	Year     int      `json:"year,omitempty"`
+	Average  float64  `json:"average"`
+	Votes    int      `json:"votes"`
+}
+
+// MemberActivity is how much a member has used their watchlist
+type MemberActivity struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Added    int    `json:"added"`
+	Done     int    `json:"done"`
+	Rated    int    `json:"rated"`
+}
+
+// Leaderboard holds every board for a guild
+type Leaderboard struct {
+	MostWatched []*TitleCount     `json:"most_watched"`
+	TopRated    []*TitleRating    `json:"top_rated"`
+	MostActive  []*MemberActivity `json:"most_active"`
+	Common      []*TitleCount     `json:"common"`  // titles on the most members' lists
+	Members     int               `json:"members"` // members with at least one entry
+}
+
+/*
+Compute every leaderboard for a guild
+
+Titles are matched case-insensitively within a category and release year, so "dune" and "Dune" count as the same movie,
+members with private watchlists are left out
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	guildID:	guild to compute leaderboards for
+	minVotes:	minimum number of ratings for the top rated board
+
+Returns:
+
+	*Leaderboard:	ptr to the leaderboards
+	error:			error object
+*/
+func FetchLeaderboard(db *sql.DB, guildID string, minVotes int) (*Leaderboard, error) {
+	var (
+		board = &Leaderboard{}
+		err   error
+	)
+
+	board.MostWatched, err = queryTitleCounts(db, "SELECT MIN(title), category, year, COUNT(*) AS n FROM entries "+
+		"WHERE done = 1 AND "+NOT_DELETED+" AND userID IN ("+guildUsersQuery(PRIVACY_GUILD)+") "+
+		"GROUP BY LOWER(title), category, year ORDER BY n DESC, MIN(title) LIMIT ?", guildID, LEADERBOARD_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	board.Common, err = queryTitleCounts(db, "SELECT MIN(title), category, year, COUNT(DISTINCT userID) AS n FROM entries "+
+		"WHERE "+NOT_DELETED+" AND userID IN ("+guildUsersQuery(PRIVACY_GUILD)+") "+
+		"GROUP BY LOWER(title), category, year HAVING n > 1 ORDER BY n DESC, MIN(title) LIMIT ?", guildID, LEADERBOARD_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT COUNT(DISTINCT userID) FROM entries WHERE " + NOT_DELETED + " AND userID IN (" + guildUsersQuery(PRIVACY_GUILD) + ")"
+	if err = db.QueryRow(query, guildID).Scan(&board.Members); err != nil {
+		return nil, err
+	}
+
+	// Top rated
+	rows, err := db.Query("SELECT MIN(title), category, year, AVG(rating) AS average, COUNT(*) AS votes FROM entries "+
+		"WHERE rating > 0 AND "+NOT_DELETED+" AND userID IN ("+guildUsersQuery(PRIVACY_GUILD)+") "+
+		"GROUP BY LOWER(title), category, year HAVING votes >= ? ORDER BY average DESC, votes DESC LIMIT ?", guildID, minVotes, LEADERBOARD_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t TitleRating
+		if err := rows.Scan(&t.Title, &t.Category, &t.Year, &t.Average, &t.Votes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		board.TopRated = append(board.TopRated, &t)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Most active, ranked on completions then additions
+	rows, err = db.Query("SELECT m.userID, m.username, COUNT(*) AS added, COALESCE(SUM(e.done), 0) AS completed, COALESCE(SUM(e.rating > 0), 0) AS rated "+
+		"FROM entries e JOIN members m ON m.userID = e.userID AND m.guildID = ? "+
+		"WHERE e."+NOT_DELETED+" AND e.userID IN ("+guildUsersQuery(PRIVACY_GUILD)+") "+
+		"GROUP BY m.userID ORDER BY completed DESC, added DESC LIMIT ?", guildID, guildID, LEADERBOARD_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var a MemberActivity
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Added, &a.Done, &a.Rated); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		board.MostActive = append(board.MostActive, &a)
+	}
+	rows.Close()
+
+	return board, rows.Err()
+}
+
+// Run a query selecting title, category and a count
+func queryTitleCounts(db *sql.DB, query string, args ...any) ([]*TitleCount, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*TitleCount
+	for rows.Next() {
+		var t TitleCount
+		if err := rows.Scan(&t.Title, &t.Category, &t.Year, &t.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, &t)
+	}
+
+	return counts, rows.Err()
+}
+
+// Render the leaderboards as an embedded message
+func (b *Leaderboard) Embed(title string) *discordgo.MessageEmbed {
+	block := func(lines []string) string {
+		if len(lines) == 0 {
+			return "-"
+		}
+		return "```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+
+	var watched, rated, active, common []string
+	for i, t := range b.MostWatched {
+		watched = append(watched, fmt.Sprintf("%2d. %s (%s) - %d", i+1, displayTitle(t.Title, t.Year), t.Category, t.Count))
+	}
+	for i, t := range b.TopRated {
+		rated = append(rated, fmt.Sprintf("%2d. %s (%s) - %.1f from %d", i+1, displayTitle(t.Title, t.Year), t.Category, t.Average, t.Votes))
+	}
+	for i, a := range b.MostActive {
+		active = append(active, fmt.Sprintf("%2d. %s - %d done, %d added, %d rated", i+1, a.Username, a.Done, a.Added, a.Rated))
+	}
+	for _, t := range b.Common {
+		marker := ""
+		if t.Count == b.Members {
+			marker = " (everyone)"
+		}
+		common = append(common, fmt.Sprintf("%d/%d %s (%s)%s", t.Count, b.Members, displayTitle(t.Title, t.Year), t.Category, marker))
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: title,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Most watched", Value: block(watched)},
+			{Name: "Top rated", Value: block(rated)},
+			{Name: "Most active", Value: block(active)},
+			{Name: "On everyone's list", Value: block(common)},
+		},
+	}
+}
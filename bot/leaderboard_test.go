@@ -0,0 +1,74 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"testing"
+)
+
+func TestFetchLeaderboardMostActive(t *testing.T) {
+	db := newTestDB(t)
+	addTestMembers(t, db)
+
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Arrival", Category: Movie},
+		&Entry{UserID: "1", Title: "Severance", Category: Show},
+		&Entry{UserID: "guild", Title: "Dune", Category: Movie},
+		&Entry{UserID: "guild", Title: "Heat", Category: Movie},
+		&Entry{UserID: "private", Title: "Dune", Category: Movie},
+	)
+	done := []struct {
+		userID string
+		title  string
+	}{
+		{"1", "Dune"},
+		{"guild", "Dune"},
+		{"guild", "Heat"},
+		{"private", "Dune"},
+	}
+	for _, d := range done {
+		if _, err := DoneEntry(db, TEST_ACTOR, d.userID, d.title, Movie, 0, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Entries in the trash don't count towards anything
+	if err := DeleteEntry(db, TEST_ACTOR, "guild", "Heat", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	board, err := FetchLeaderboard(db, "g1", DEFAULT_MIN_VOTES)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MemberActivity{
+		{UserID: "1", Username: "1", Added: 3, Done: 1},
+		{UserID: "guild", Username: "guild", Added: 1, Done: 1},
+	}
+	if len(board.MostActive) != len(want) {
+		t.Fatalf("got %d active members, want %d", len(board.MostActive), len(want))
+	}
+	for i, a := range board.MostActive {
+		if *a != want[i] {
+			t.Errorf("rank %d: got %+v, want %+v", i+1, *a, want[i])
+		}
+	}
+}
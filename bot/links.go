@@ -0,0 +1,479 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Link is an entry link checked by ParseLink
+type Link struct {
+	URL        string   `json:"url"`                   // canonical form of the link
+	Kind       LinkKind `json:"kind"`                  // what the link is for
+	Site       string   `json:"site,omitempty"`        // recognized site, ex. "imdb", empty for other sites
+	ExternalID string   `json:"external_id,omitempty"` // ID of the title on the site, ex. "imdb:tt0068646"
+}
+
+// LinkKind is what a link is for
+type LinkKind string
+
+const (
+	// Enumerations for link kinds
+	LINK_INFO      LinkKind = "info"      // a page about the title, ex. IMDb
+	LINK_TRAILER   LinkKind = "trailer"   // a trailer or clip
+	LINK_STREAMING LinkKind = "streaming" // where to watch it
+	LINK_OTHER     LinkKind = "other"
+)
+
+// Link kinds from most to least relevant, the most relevant link is shown inline with an entry
+var LINK_KINDS = []LinkKind{LINK_INFO, LINK_TRAILER, LINK_STREAMING, LINK_OTHER}
+
+// linkSite recognizes the links of one site and rewrites them to a canonical form
+type linkSite struct {
+	name  string
+	kind  LinkKind // kind of the site's links unless the user says otherwise
+	hosts []string // hosts without "www."
+
+	// Canonical URL and external ID for a link on this site, ok is false if the path isn't a title,
+	// nil keeps links as given
+	canonical func(u *url.URL) (link string, externalID string, ok bool)
+}
+
+var (
+	IMDB_PATH       = regexp.MustCompile(`^/title/(tt\d+)`)
+	LETTERBOXD_PATH = regexp.MustCompile(`^/(?:[^/]+/)?film/([a-z0-9-]+)`) // also matches member pages, ex. /username/film/dune/
+	MAL_PATH        = regexp.MustCompile(`^/anime/(\d+)`)
+	TRAKT_PATH      = regexp.MustCompile(`^/(movies|shows)/([a-z0-9-]+)`)
+	YOUTUBE_ID      = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+)
+
+// Sites with links that are canonicalized, every other http(s) link is kept as given
+var LINK_SITES = []*linkSite{
+	{
+		name:  "imdb",
+		kind:  LINK_INFO,
+		hosts: []string{"imdb.com", "m.imdb.com"},
+		canonical: func(u *url.URL) (string, string, bool) {
+			match := IMDB_PATH.FindStringSubmatch(u.Path)
+			if match == nil {
+				return "", "", false
+			}
+			return fmt.Sprintf("https://www.imdb.com/title/%s/", match[1]), "imdb:" + match[1], true
+		},
+	},
+	{
+		name:  "letterboxd",
+		kind:  LINK_INFO,
+		hosts: []string{"letterboxd.com"},
+		canonical: func(u *url.URL) (string, string, bool) {
+			match := LETTERBOXD_PATH.FindStringSubmatch(strings.ToLower(u.Path))
+			if match == nil {
+				return "", "", false
+			}
+			return fmt.Sprintf("https://letterboxd.com/film/%s/", match[1]), "letterboxd:" + match[1], true
+		},
+	},
+	{
+		name:  "mal",
+		kind:  LINK_INFO,
+		hosts: []string{"myanimelist.net"},
+		canonical: func(u *url.URL) (string, string, bool) {
+			match := MAL_PATH.FindStringSubmatch(u.Path)
+			if match == nil {
+				return "", "", false
+			}
+			return fmt.Sprintf("https://myanimelist.net/anime/%s", match[1]), "mal:" + match[1], true
+		},
+	},
+	{
+		name:  "trakt",
+		kind:  LINK_INFO,
+		hosts: []string{"trakt.tv", "app.trakt.tv"},
+		canonical: func(u *url.URL) (string, string, bool) {
+			match := TRAKT_PATH.FindStringSubmatch(strings.ToLower(u.Path))
+			if match == nil {
+				return "", "", false
+			}
+			return fmt.Sprintf("https://trakt.tv/%s/%s", match[1], match[2]), fmt.Sprintf("trakt:%s/%s", match[1], match[2]), true
+		},
+	},
+	{
+		// Videos are trailers and clips rather than the title itself, so they have no external ID
+		name:  "youtube",
+		kind:  LINK_TRAILER,
+		hosts: []string{"youtube.com", "m.youtube.com", "music.youtube.com", "youtu.be"},
+		canonical: func(u *url.URL) (string, string, bool) {
+			var id string
+			switch {
+			case strings.TrimPrefix(u.Hostname(), "www.") == "youtu.be":
+				id = strings.Trim(u.Path, "/")
+			case u.Path == "/watch":
+				id = u.Query().Get("v")
+			case strings.HasPrefix(u.Path, "/shorts/"), strings.HasPrefix(u.Path, "/embed/"), strings.HasPrefix(u.Path, "/live/"):
+				id = strings.Split(u.Path, "/")[2]
+			}
+
+			if !YOUTUBE_ID.MatchString(id) {
+				return "", "", false
+			}
+			return "https://www.youtube.com/watch?v=" + id, "", true
+		},
+	},
+	{name: "netflix", kind: LINK_STREAMING, hosts: []string{"netflix.com"}},
+	{name: "prime", kind: LINK_STREAMING, hosts: []string{"primevideo.com"}},
+	{name: "disney", kind: LINK_STREAMING, hosts: []string{"disneyplus.com"}},
+	{name: "hulu", kind: LINK_STREAMING, hosts: []string{"hulu.com"}},
+	{name: "max", kind: LINK_STREAMING, hosts: []string{"max.com", "play.max.com"}},
+	{name: "crunchyroll", kind: LINK_STREAMING, hosts: []string{"crunchyroll.com"}},
+	{name: "apple", kind: LINK_STREAMING, hosts: []string{"tv.apple.com"}},
+}
+
+/*
+Validate a link and rewrite links to recognized sites to a canonical form
+
+Links without a scheme are assumed to be https, ex. "imdb.com/title/tt0068646".
+The link's kind is guessed from its site, ex. YouTube links are trailers
+
+Params:
+
+	raw:	link as the user typed it
+
+Returns:
+
+	*Link:	ptr to the parsed link
+	error:	InvalidLinkError if raw isn't an http(s) URL
+*/
+func ParseLink(raw string) (*Link, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") {
+		return nil, &InvalidLinkError{strings.TrimPrefix(raw, "https://")}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	for _, site := range LINK_SITES {
+		for _, h := range site.hosts {
+			if host != h {
+				continue
+			}
+			if site.canonical != nil {
+				if link, id, ok := site.canonical(u); ok {
+					return &Link{URL: link, Kind: site.kind, Site: site.name, ExternalID: id}, nil
+				}
+			}
+			return &Link{URL: u.String(), Kind: site.kind, Site: site.name}, nil
+		}
+	}
+
+	return &Link{URL: u.String(), Kind: LINK_OTHER}, nil
+}
+
+/*
+Add a link to an entry, canonicalized, and take its external ID if the link names one
+
+Adding a link the entry already has changes its kind.
+
+Params:
+
+	raw:	link as the user typed it
+	kind:	what the link is for, empty to guess from the link's site
+
+Returns:
+
+	*Link:	ptr to the added link
+	error:	InvalidLinkError or InvalidLinkKindError
+*/
+func (e *Entry) AddLink(raw string, kind LinkKind) (*Link, error) {
+	link, err := ParseLink(raw)
+	if err != nil {
+		return nil, err
+	}
+	if kind != "" {
+		if err := kind.IsValid(); err != nil {
+			return nil, err
+		}
+		link.Kind = kind
+	}
+
+	e.removeLinks(func(l *Link) bool { return l.URL == link.URL })
+	e.Links = append(e.Links, link)
+	e.sortLinks()
+
+	if link.ExternalID != "" {
+		e.ExternalID = link.ExternalID
+	}
+	return link, nil
+}
+
+// Remove the links that match, returns the removed links
+func (e *Entry) removeLinks(match func(*Link) bool) []*Link {
+	var kept, removed []*Link
+	for _, l := range e.Links {
+		if match(l) {
+			removed = append(removed, l)
+		} else {
+			kept = append(kept, l)
+		}
+	}
+
+	e.Links = kept
+	e.sortLinks()
+	return removed
+}
+
+// Order links from most to least relevant and show the most relevant one as the entry's link
+func (e *Entry) sortLinks() {
+	sort.SliceStable(e.Links, func(i, j int) bool {
+		return e.Links[i].Kind.rank() < e.Links[j].Kind.rank()
+	})
+
+	e.Link = ""
+	if len(e.Links) > 0 {
+		e.Link = e.Links[0].URL
+	}
+}
+
+/*
+Add a link to an entry in the database
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	raw:		link to add, canonicalized before it's saved
+	kind:		what the link is for, empty to guess from the link's site
+
+Returns:
+
+	*Entry:	ptr to the entry, with its links
+	*Link:	ptr to the added link
+	error:	error object
+*/
+func LinkEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, raw string, kind LinkKind) (*Entry, *Link, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := entry.clone()
+
+	link, err := entry.AddLink(raw, kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err = entry.writeLinks(tx); err != nil {
+		return nil, nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_LINK, before); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	slog.Debug("links.LinkEntry", "user", userID, "title", entry.Title, "category", entry.Category, "link", link.URL, "kind", link.Kind)
+	return entry, link, nil
+}
+
+/*
+Remove links from an entry in the database
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	target:		link to remove, or a kind to remove every link of that kind
+
+Returns:
+
+	*Entry:		ptr to the entry, with its remaining links
+	[]*Link:	the removed links
+	error:		LinkNotFoundError if nothing matched target
+*/
+func UnlinkEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, target string) (*Entry, []*Link, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := entry.clone()
+
+	// Compare canonical forms so any way of writing the link matches
+	kind := LinkKind(strings.ToLower(target))
+	canonical := target
+	if link, err := ParseLink(target); err == nil {
+		canonical = link.URL
+	}
+
+	removed := entry.removeLinks(func(l *Link) bool { return l.Kind == kind || l.URL == canonical })
+	if len(removed) == 0 {
+		return nil, nil, &LinkNotFoundError{entry.DisplayTitle(), target}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err = entry.writeLinks(tx); err != nil {
+		return nil, nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_UNLINK, before); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	slog.Debug("links.UnlinkEntry", "user", userID, "title", entry.Title, "category", entry.Category, "removed", len(removed))
+	return entry, removed, nil
+}
+
+// Find an entry and attach its tags, links and availability
+func findEntryWithDetails(db *sql.DB, userID string, title string, category Category, year int) (*Entry, error) {
+	entry, err := findEntry(db, false, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+	return entry, (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db)
+}
+
+// Replace an entry's links in the database with entry.Links, and save its external ID
+func (e *Entry) writeLinks(tx *sql.Tx) error {
+	if _, err := tx.Exec("DELETE FROM links WHERE "+ENTRY_KEY, e.key()...); err != nil {
+		return err
+	}
+	for _, link := range e.Links {
+		_, err := tx.Exec("INSERT INTO links(userID, title, category, year, url, kind) VALUES(?, ?, ?, ?, ?, ?)", append(e.key(), link.URL, link.Kind)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec("UPDATE entries SET externalID = ? WHERE "+ENTRY_KEY, append([]any{e.ExternalID}, e.key()...)...)
+	return err
+}
+
+/*
+Attach links to the entries of a watchlist
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	error:	error object
+*/
+func (w *Watchlist) loadLinks(db querier) error {
+	// Same indexing as loadTags
+	index := make(map[entryKey]*Entry, len(w.Entries))
+	users := make(map[string]bool)
+	for _, e := range w.Entries {
+		e.Links = nil
+		index[e.mapKey()] = e
+		users[e.UserID] = true
+	}
+
+	for userID := range users {
+		rows, err := db.Query("SELECT title, category, year, url, kind FROM links WHERE userID = ? ORDER BY rowid", userID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var (
+				linked = Entry{UserID: userID}
+				link   Link
+			)
+			if err := rows.Scan(&linked.Title, &linked.Category, &linked.Year, &link.URL, &link.Kind); err != nil {
+				rows.Close()
+				return err
+			}
+
+			// Links saved before they were validated may not parse, they're kept as they are
+			if parsed, err := ParseLink(link.URL); err == nil {
+				link.Site, link.ExternalID = parsed.Site, parsed.ExternalID
+			}
+			if e, ok := index[linked.mapKey()]; ok {
+				e.Links = append(e.Links, &link)
+			}
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range w.Entries {
+		e.sortLinks()
+	}
+	return nil
+}
+
+// Position of a kind in LINK_KINDS, unknown kinds go last
+func (k LinkKind) rank() int {
+	for i, kind := range LINK_KINDS {
+		if k == kind {
+			return i
+		}
+	}
+	return len(LINK_KINDS)
+}
+
+// enum validation
+func (k *LinkKind) IsValid() error {
+	switch *k {
+	case LINK_INFO, LINK_TRAILER, LINK_STREAMING, LINK_OTHER:
+		return nil
+	default:
+		return &InvalidLinkKindError{k}
+	}
+}
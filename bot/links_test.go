@@ -0,0 +1,108 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Link
+	}{
+		// Recognized sites are rewritten to one canonical form
+		{"https://www.imdb.com/title/tt0068646/", Link{"https://www.imdb.com/title/tt0068646/", LINK_INFO, "imdb", "imdb:tt0068646"}},
+		{"imdb.com/title/tt0068646", Link{"https://www.imdb.com/title/tt0068646/", LINK_INFO, "imdb", "imdb:tt0068646"}},
+		{"http://m.imdb.com/title/tt0068646/reviews?ref_=tt_urv", Link{"https://www.imdb.com/title/tt0068646/", LINK_INFO, "imdb", "imdb:tt0068646"}},
+		{"  HTTPS://WWW.IMDB.COM/title/tt0068646/  ", Link{"https://www.imdb.com/title/tt0068646/", LINK_INFO, "imdb", "imdb:tt0068646"}},
+		{"https://letterboxd.com/film/Dune-2021/", Link{"https://letterboxd.com/film/dune-2021/", LINK_INFO, "letterboxd", "letterboxd:dune-2021"}},
+		{"https://letterboxd.com/someone/film/dune-2021/activity/", Link{"https://letterboxd.com/film/dune-2021/", LINK_INFO, "letterboxd", "letterboxd:dune-2021"}},
+		{"https://myanimelist.net/anime/5114/Fullmetal_Alchemist__Brotherhood", Link{"https://myanimelist.net/anime/5114", LINK_INFO, "mal", "mal:5114"}},
+		{"https://app.trakt.tv/shows/severance/seasons/1", Link{"https://trakt.tv/shows/severance", LINK_INFO, "trakt", "trakt:shows/severance"}},
+
+		// Videos are canonicalized without an external ID
+		{"https://youtu.be/n9xhJrPXop4?t=10", Link{"https://www.youtube.com/watch?v=n9xhJrPXop4", LINK_TRAILER, "youtube", ""}},
+		{"https://www.youtube.com/watch?v=n9xhJrPXop4&list=PL123", Link{"https://www.youtube.com/watch?v=n9xhJrPXop4", LINK_TRAILER, "youtube", ""}},
+		{"https://youtube.com/shorts/n9xhJrPXop4", Link{"https://www.youtube.com/watch?v=n9xhJrPXop4", LINK_TRAILER, "youtube", ""}},
+
+		// Pages on a recognized site that aren't titles, and other sites, are kept as given
+		{"https://www.imdb.com/chart/top/", Link{"https://www.imdb.com/chart/top/", LINK_INFO, "imdb", ""}},
+		{"https://www.youtube.com/watch?v=short", Link{"https://www.youtube.com/watch?v=short", LINK_TRAILER, "youtube", ""}},
+		{"https://notimdb.com/title/tt0068646/", Link{"https://notimdb.com/title/tt0068646/", LINK_OTHER, "", ""}},
+		{"https://www.netflix.com/title/80100172", Link{"https://www.netflix.com/title/80100172", LINK_STREAMING, "netflix", ""}},
+		{"http://example.com/Dune?x=1", Link{"http://example.com/Dune?x=1", LINK_OTHER, "", ""}},
+	}
+	for _, test := range tests {
+		got, err := ParseLink(test.raw)
+		if err != nil {
+			t.Errorf("ParseLink(%q): %v", test.raw, err)
+			continue
+		}
+		if *got != test.want {
+			t.Errorf("ParseLink(%q) = %+v, want %+v", test.raw, *got, test.want)
+		}
+	}
+}
+
+func TestParseLinkInvalid(t *testing.T) {
+	for _, raw := range []string{"", "dune", "ftp://example.com/dune", "javascript:alert(1)", "https://localhost/dune", "https://exa mple.com"} {
+		var invalid *InvalidLinkError
+		if link, err := ParseLink(raw); !errors.As(err, &invalid) {
+			t.Errorf("ParseLink(%q) = %+v, %v, want InvalidLinkError", raw, link, err)
+		}
+	}
+}
+
+func TestAddLink(t *testing.T) {
+	e := &Entry{Title: "The Godfather", Category: Movie}
+	if _, err := e.AddLink("https://youtu.be/n9xhJrPXop4", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.AddLink("imdb.com/title/tt0068646", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The most relevant link is shown as the entry's link, and its external ID is taken
+	if e.Link != "https://www.imdb.com/title/tt0068646/" || e.ExternalID != "imdb:tt0068646" || len(e.Links) != 2 {
+		t.Errorf("got link %q, external ID %q and links %v", e.Link, e.ExternalID, e.Links)
+	}
+
+	// Adding a link again changes its kind instead of adding it twice
+	if _, err := e.AddLink("https://www.youtube.com/watch?v=n9xhJrPXop4", LINK_STREAMING); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Links) != 2 || e.Links[1].Kind != LINK_STREAMING {
+		t.Errorf("got links %+v, want the trailer turned into a streaming link", e.Links)
+	}
+
+	// Invalid links and kinds leave the entry alone
+	var badKind *InvalidLinkKindError
+	if _, err := e.AddLink("https://example.com", "poster"); !errors.As(err, &badKind) {
+		t.Errorf("AddLink with kind poster = %v, want InvalidLinkKindError", err)
+	}
+	var badLink *InvalidLinkError
+	if _, err := e.AddLink("not a link", ""); !errors.As(err, &badLink) {
+		t.Errorf("AddLink(invalid) = %v, want InvalidLinkError", err)
+	}
+	if len(e.Links) != 2 {
+		t.Errorf("got %d links after failed adds, want 2", len(e.Links))
+	}
+}
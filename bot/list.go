@@ -30,6 +30,7 @@ import (
 
 type Watchlist struct {
 	UserID  string   `json:"user_id"`
+	GuildID string   `json:"guild_id,omitempty"` // set for guild-wide watchlists, which have no UserID
 	Entries []*Entry `json:"entries"`
 }
 
@@ -40,30 +41,39 @@ const (
 	SORT_TITLE    SortBy = "title"
 	SORT_DATE     SortBy = "date"
 	SORT_CATEGORY SortBy = "category"
+	SORT_PRIORITY SortBy = "priority"
 )
 
 /*
-Fetch watchlist from the database if it exists
+Fetch a user's watchlist on behalf of a viewer, respecting the owner's privacy setting
 
 Params:
 
 	db: 		ptr to sqlite3 database connection
-	userID: 	user ID we are searching for entries for
+	viewer:		who is reading the watchlist
+	ownerID: 	user ID we are searching for entries for
 	watched:	true if we want all entries, false if we want only unwatched entries
 
 Returns:
 
 	*Watchlist: 	ptr to watchlist object
-	error:			error object
+	error:			PrivateWatchlistError or a database error
 */
-func FetchWatchlist(db *sql.DB, userID string, watched bool) (*Watchlist, error) {
+func FetchWatchlist(db *sql.DB, viewer *Viewer, ownerID string, watched bool) (*Watchlist, error) {
+	if err := CanView(db, viewer, ownerID); err != nil {
+		return nil, err
+	}
+	return fetchWatchlist(db, ownerID, watched)
+}
 
-	var watchlist *Watchlist
+// Same as FetchWatchlist without the privacy check, for the owner's own use
+func fetchWatchlist(db *sql.DB, userID string, watched bool) (*Watchlist, error) {
+
+	watchlist := &Watchlist{UserID: userID}
 
 	// If an entry for the user exists, get it + all other entries
 	exists, err := checkWatchlist(db, userID)
 	if exists {
-		watchlist := &Watchlist{UserID: userID}
 		err = watchlist.populate(db, watched)
 	}
 
@@ -85,11 +95,31 @@ Returns:
 */
 func checkWatchlist(db *sql.DB, userID string) (bool, error) {
 	exists := false
-	query := "SELECT EXISTS(SELECT 1 FROM entries WHERE userID = ? LIMIT 1)"
+	query := "SELECT EXISTS(SELECT 1 FROM entries WHERE userID = ? AND " + NOT_DELETED + " LIMIT 1)"
 	err := db.QueryRow(query, userID).Scan(&exists)
 	return exists, err
 }
 
+/*
+Count the entries in a user's queue, leaving out the trash
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user ID of the queue's owner
+
+Returns:
+
+	int:		number of entries in the queue
+	error:		error object
+*/
+func queueLength(db *sql.DB, userID string) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM entries WHERE userID = ? AND " + NOT_DELETED
+	err := db.QueryRow(query, userID).Scan(&n)
+	return n, err
+}
+
 /*
 Populate a watchlist with entries that match the watchlist's user ID
 
@@ -104,33 +134,84 @@ Returns:
 */
 func (w *Watchlist) populate(db *sql.DB, watched bool) error {
 	// Get all entries from the database for the user
-	query := "SELECT (userID, date, title, category, done, rating, link) " +
-		"FROM entries WHERE userID = ?"
+	query := "SELECT " + ENTRY_COLUMNS + " FROM entries WHERE userID = ? AND " + NOT_DELETED
 
 	if !watched {
 		query += " AND done = 0"
 	}
 
-	rows, err := db.Query(query, w.UserID)
+	entries, err := queryEntries(db, query, w.UserID)
 	if err != nil {
 		return err
 	}
+
+	w.Entries = entries
+	return w.loadDetails(db)
+}
+
+// Attach tags, links, availability and watch logs to the entries of a watchlist, they're stored in their own tables
+func (w *Watchlist) loadDetails(db querier) error {
+	if err := w.loadTags(db); err != nil {
+		return err
+	}
+	if err := w.loadLinks(db); err != nil {
+		return err
+	}
+	if err := w.loadAvailability(db); err != nil {
+		return err
+	}
+	return w.loadWatches(db)
+}
+
+/*
+Fetch the combined watchlist of every member of a guild whose privacy setting allows it
+
+Params:
+
+	db: 		ptr to sqlite3 database connection
+	guildID: 	guild to fetch entries for
+	audience:	who will see the watchlist, PRIVACY_GUILD for members or PRIVACY_LINK for share link visitors
+	watched:	true if we want all entries, false if we want only unwatched entries
+
+Returns:
+
+	*Watchlist: 	ptr to watchlist object (with GuildID set instead of UserID)
+	error:			error object
+*/
+func FetchGuildWatchlist(db *sql.DB, guildID string, audience Privacy, watched bool) (*Watchlist, error) {
+	query := "SELECT " + ENTRY_COLUMNS + " FROM entries WHERE " + NOT_DELETED + " AND userID IN (" + guildUsersQuery(audience) + ")"
+	if !watched {
+		query += " AND done = 0"
+	}
+
+	entries, err := queryEntries(db, query, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	watchlist := &Watchlist{GuildID: guildID, Entries: entries}
+	return watchlist, watchlist.loadDetails(db)
+}
+
+// Run a query selecting ENTRY_COLUMNS and create Entry objects for each row
+func queryEntries(db querier, query string, args ...any) ([]*Entry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	// Loop through row of query results and create Entry objects for each
 	var entries []*Entry
 	for rows.Next() {
-		var e Entry
-		err := rows.Scan(&e.UserID, &e.Title, &e.Category, &e.Date, &e.Link)
+		e, err := scanEntry(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		entries = append(entries, &e)
+		entries = append(entries, e)
 	}
 
-	w.Entries = entries
-	return nil
+	return entries, rows.Err()
 }
 
 /*
@@ -138,7 +219,7 @@ Sort the watchlist by the provided sort_by option
 
 Params:
 
-	sort_by: 	sort_by option (one of SORT_TITLE, SORT_DATE, SORT_CATEGORY)
+	sort_by: 	sort_by option (one of SORT_TITLE, SORT_DATE, SORT_CATEGORY, SORT_PRIORITY)
 */
 func (w *Watchlist) Sort(sort_by SortBy) {
 
@@ -164,6 +245,11 @@ func (w *Watchlist) Sort(sort_by SortBy) {
 		sort.Slice(w.Entries, func(i, j int) bool {
 			return w.Entries[i].Category < w.Entries[j].Category
 		})
+
+	case SORT_PRIORITY:
+		sort.Slice(w.Entries, func(i, j int) bool {
+			return w.Entries[i].Priority < w.Entries[j].Priority
+		})
 	}
 
 	slog.Debug("watchlist.Sort", "watchlist", w)
@@ -171,7 +257,12 @@ func (w *Watchlist) Sort(sort_by SortBy) {
 
 // stringer method
 func (w *Watchlist) String() string {
-	watchlistString := fmt.Sprintf("Watchlist for %s:\n", w.UserID)
+	owner := w.UserID
+	if owner == "" {
+		owner = "guild " + w.GuildID
+	}
+
+	watchlistString := fmt.Sprintf("Watchlist for %s:\n", owner)
 	watchlistString += strings.Repeat("-", len(watchlistString))
 
 	for _, e := range w.Entries {
@@ -183,7 +274,7 @@ func (w *Watchlist) String() string {
 // enum validation
 func (s *SortBy) IsValid() error {
 	switch *s {
-	case SORT_TITLE, SORT_DATE, SORT_CATEGORY:
+	case SORT_TITLE, SORT_DATE, SORT_CATEGORY, SORT_PRIORITY:
 		return nil
 	default:
 		return &InvalidSortByError{s}
@@ -0,0 +1,84 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Member represents a user that has used the bot in a guild
+type Member struct {
+	GuildID  string    `json:"guild_id"`
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Record that a user used the bot in a guild, keeping their latest username
+func RecordMember(db *sql.DB, guildID string, userID string, username string) error {
+	query := "INSERT INTO members(guildID, userID, username, lastSeen) VALUES(?, ?, ?, ?) " +
+		"ON CONFLICT(guildID, userID) DO UPDATE SET username = excluded.username, lastSeen = excluded.lastSeen"
+	_, err := db.Exec(query, guildID, userID, username, time.Now().UTC())
+	return err
+}
+
+/*
+Fetch every member that has used the bot in a guild
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	guildID:	guild to fetch members for
+
+Returns:
+
+	[]*Member:	members, ordered by username
+	error:		error object
+*/
+func FetchMembers(db *sql.DB, guildID string) ([]*Member, error) {
+	rows, err := db.Query("SELECT guildID, userID, username, lastSeen FROM members WHERE guildID = ? ORDER BY username", guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.GuildID, &m.UserID, &m.Username, &m.LastSeen); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+
+	return members, rows.Err()
+}
+
+// Latest username seen for a user in any guild, or their user ID if they've never been seen
+func FetchUsername(db *sql.DB, userID string) (string, error) {
+	var username string
+	err := db.QueryRow("SELECT username FROM members WHERE userID = ? ORDER BY lastSeen DESC LIMIT 1", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return userID, nil
+	}
+	return username, err
+}
@@ -0,0 +1,222 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Metadata is what a metadata provider knows about a title
+type Metadata struct {
+	ID       string   `json:"id"` // provider specific, ex. imdb:tt0068646
+	Title    string   `json:"title"`
+	Category Category `json:"category"`
+	Year     int      `json:"year"`
+	Runtime  int      `json:"runtime"` // minutes, per episode for shows and anime
+	Genres   []string `json:"genres"`
+	Poster   string   `json:"poster"`
+	Episodes int      `json:"episodes"` // 0 for movies
+
+	// Where the title can be watched, in every region the provider knows about
+	Availability []*Availability `json:"availability,omitempty"`
+}
+
+// MetadataProvider looks up titles in a movie/show/anime database
+type MetadataProvider interface {
+	// Search for titles matching a title, best match first (empty category matches any)
+	Search(title string, category Category) ([]*Metadata, error)
+
+	// Fetch a title by its ID, MetadataNotFoundError if there is none
+	Fetch(id string) (*Metadata, error)
+}
+
+// Provider used to normalize and enrich new entries, nil to leave entries as typed
+var METADATA_PROVIDER MetadataProvider
+
+// FixtureProvider is a MetadataProvider backed by a local JSON file, for offline use
+type FixtureProvider struct {
+	titles []*Metadata
+	byID   map[string]*Metadata
+}
+
+/*
+Load a fixture dataset
+
+Params:
+
+	path:	JSON file holding an array of Metadata objects
+
+Returns:
+
+	*FixtureProvider:	ptr to the provider
+	error:				error object
+*/
+func NewFixtureProvider(path string) (*FixtureProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &FixtureProvider{byID: make(map[string]*Metadata)}
+	if err = json.Unmarshal(data, &p.titles); err != nil {
+		return nil, err
+	}
+	for _, m := range p.titles {
+		p.byID[m.ID] = m
+	}
+
+	slog.Debug("metadata.NewFixtureProvider", "path", path, "titles", len(p.titles))
+	return p, nil
+}
+
+// Titles whose normalized title matches exactly come first, then ones containing it, newest first
+func (p *FixtureProvider) Search(title string, category Category) ([]*Metadata, error) {
+	query := normalizeTitle(title)
+	if query == "" {
+		return nil, nil
+	}
+
+	type match struct {
+		*Metadata
+		exact bool
+	}
+
+	var matches []match
+	for _, m := range p.titles {
+		if category != "" && m.Category != category {
+			continue
+		}
+
+		normalized := normalizeTitle(m.Title)
+		if normalized == query || strings.Contains(normalized, query) {
+			matches = append(matches, match{m, normalized == query})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].exact != matches[j].exact {
+			return matches[i].exact
+		}
+		return matches[i].Year > matches[j].Year
+	})
+
+	results := make([]*Metadata, len(matches))
+	for i, m := range matches {
+		results[i] = m.Metadata
+	}
+	return results, nil
+}
+
+func (p *FixtureProvider) Fetch(id string) (*Metadata, error) {
+	m, ok := p.byID[id]
+	if !ok {
+		return nil, &MetadataNotFoundError{id}
+	}
+	return m, nil
+}
+
+/*
+Normalize a title for matching: lowercase, without punctuation or a leading "the"
+
+Example:
+
+	normalizeTitle("The Lord of the Rings: The Two Towers") == "lord of the rings the two towers"
+*/
+func normalizeTitle(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 && words[0] == "the" {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+/*
+Fill in an entry's title and details from a metadata provider
+
+Only a title that matches the entry's exactly (ignoring case, punctuation and a leading "the")
+is used, so a typo never turns into a different title. If the entry has a release year only that
+year's title is used, otherwise the newest one is. An entry with an external ID (ex. from an IMDb link)
+is looked up by that ID first. Runtimes the user already set are kept
+
+Params:
+
+	provider:	provider to look the entry up in, nil does nothing
+
+Returns:
+
+	*Metadata:	ptr to the metadata that was used, nil if nothing matched
+	error:		error object
+*/
+func (e *Entry) Enrich(provider MetadataProvider) (*Metadata, error) {
+	if provider == nil {
+		return nil, nil
+	}
+
+	// A link to the title names it exactly, so it wins over searching by title
+	var m *Metadata
+	if e.ExternalID != "" {
+		var notFound *MetadataNotFoundError
+		found, err := provider.Fetch(e.ExternalID)
+		if err != nil && !errors.As(err, &notFound) {
+			return nil, err
+		}
+		if found != nil && found.Category == e.Category {
+			m = found
+		}
+	}
+
+	if m == nil {
+		results, err := provider.Search(e.Title, e.Category)
+		if err != nil {
+			return nil, err
+		}
+
+		// Search results are newest first
+		for _, result := range results {
+			if normalizeTitle(result.Title) == normalizeTitle(e.Title) && (e.Year == 0 || result.Year == e.Year) {
+				m = result
+				break
+			}
+		}
+	}
+	if m == nil {
+		return nil, nil
+	}
+
+	e.Title = m.Title
+	e.Year = m.Year
+	e.ExternalID = m.ID
+	e.Genres = m.Genres
+	e.Poster = m.Poster
+	e.Episodes = m.Episodes
+	if e.Runtime == 0 {
+		e.Runtime = m.Runtime
+	}
+
+	slog.Debug("metadata.Enrich", "title", e.Title, "id", m.ID)
+	return m, nil
+}
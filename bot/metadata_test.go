@@ -0,0 +1,165 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import "testing"
+
+// A small dataset with remakes, a sequel and a show sharing a movie's title
+func testProvider() *FixtureProvider {
+	p := &FixtureProvider{
+		titles: []*Metadata{
+			{ID: "imdb:tt0068646", Title: "The Godfather", Category: Movie, Year: 1972, Runtime: 175, Genres: []string{"crime", "drama"}},
+			{ID: "imdb:tt0071562", Title: "The Godfather Part II", Category: Movie, Year: 1974, Runtime: 202},
+			{ID: "imdb:tt0087182", Title: "Dune", Category: Movie, Year: 1984, Runtime: 137},
+			{ID: "imdb:tt1160419", Title: "Dune", Category: Movie, Year: 2021, Runtime: 155, Poster: "https://example.com/dune.jpg"},
+			{ID: "imdb:tt0142032", Title: "Dune", Category: Show, Year: 2000, Runtime: 95, Episodes: 3},
+			{ID: "imdb:tt4633694", Title: "Spider-Man: Into the Spider-Verse", Category: Movie, Year: 2018, Runtime: 117},
+		},
+		byID: make(map[string]*Metadata),
+	}
+	for _, m := range p.titles {
+		p.byID[m.ID] = m
+	}
+	return p
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"The Godfather", "godfather"},
+		{"the godfather", "godfather"},
+		{"  The   Godfather  ", "godfather"},
+		{"The Lord of the Rings: The Two Towers", "lord of the rings the two towers"},
+		{"Spider-Man: Into the Spider-Verse", "spider man into the spider verse"},
+		{"WALL·E", "wall e"},
+		{"Amélie", "amélie"},
+		{"2001: A Space Odyssey", "2001 a space odyssey"},
+		{"The", "the"},
+		{"Theodore Rex", "theodore rex"},
+		{"", ""},
+		{"?!", ""},
+	}
+	for _, test := range tests {
+		if got := normalizeTitle(test.title); got != test.want {
+			t.Errorf("normalizeTitle(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+}
+
+func TestFixtureProviderSearch(t *testing.T) {
+	p := testProvider()
+
+	tests := []struct {
+		title    string
+		category Category
+		want     []string // IDs, best match first
+	}{
+		// Exact matches first, newest first
+		{"dune", "", []string{"imdb:tt1160419", "imdb:tt0142032", "imdb:tt0087182"}},
+		{"Dune", Movie, []string{"imdb:tt1160419", "imdb:tt0087182"}},
+		{"dune", Show, []string{"imdb:tt0142032"}},
+		{"godfather", Movie, []string{"imdb:tt0068646", "imdb:tt0071562"}},
+		{"the godfather part ii", "", []string{"imdb:tt0071562"}},
+		{"spider man", "", []string{"imdb:tt4633694"}},
+		{"alien", "", nil},
+		{"dune", Anime, nil},
+		{"", "", nil},
+	}
+	for _, test := range tests {
+		results, err := p.Search(test.title, test.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, m := range results {
+			got = append(got, m.ID)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("Search(%q, %q) = %v, want %v", test.title, test.category, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("Search(%q, %q) = %v, want %v", test.title, test.category, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	p := testProvider()
+
+	tests := []struct {
+		name    string
+		entry   Entry
+		want    string // ID of the metadata used, empty if nothing should match
+		title   string
+		year    int
+		runtime int
+	}{
+		{"newest release", Entry{Title: "dune", Category: Movie}, "imdb:tt1160419", "Dune", 2021, 155},
+		{"release year", Entry{Title: "Dune", Category: Movie, Year: 1984}, "imdb:tt0087182", "Dune", 1984, 137},
+		{"category", Entry{Title: "Dune", Category: Show}, "imdb:tt0142032", "Dune", 2000, 95},
+		{"proper title", Entry{Title: "godfather", Category: Movie}, "imdb:tt0068646", "The Godfather", 1972, 175},
+		{"punctuation", Entry{Title: "spider man into the spider verse", Category: Movie}, "imdb:tt4633694", "Spider-Man: Into the Spider-Verse", 2018, 117},
+		{"runtime kept", Entry{Title: "Dune", Category: Movie, Year: 2021, Runtime: 160}, "imdb:tt1160419", "Dune", 2021, 160},
+
+		// An external ID wins over the title
+		{"external ID", Entry{Title: "Dune", Category: Movie, ExternalID: "imdb:tt0087182"}, "imdb:tt0087182", "Dune", 1984, 137},
+		{"external ID other category", Entry{Title: "Dune", Category: Movie, ExternalID: "imdb:tt0142032"}, "imdb:tt1160419", "Dune", 2021, 155},
+		{"unknown external ID", Entry{Title: "Dune", Category: Movie, ExternalID: "imdb:tt9999999"}, "imdb:tt1160419", "Dune", 2021, 155},
+
+		// Partial matches and typos are left as typed
+		{"partial", Entry{Title: "godfather part", Category: Movie}, "", "godfather part", 0, 0},
+		{"typo", Entry{Title: "godfathr", Category: Movie}, "", "godfathr", 0, 0},
+		{"no such year", Entry{Title: "dune", Category: Movie, Year: 2000}, "", "dune", 2000, 0},
+		{"no such category", Entry{Title: "dune", Category: Anime}, "", "dune", 0, 0},
+	}
+	for _, test := range tests {
+		e := test.entry
+		m, err := e.Enrich(p)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var got string
+		if m != nil {
+			got = m.ID
+			if e.ExternalID != m.ID {
+				t.Errorf("%s: external ID is %q, want %q", test.name, e.ExternalID, m.ID)
+			}
+		}
+		if got != test.want {
+			t.Errorf("%s: used %q, want %q", test.name, got, test.want)
+		}
+		if e.Title != test.title || e.Year != test.year || e.Runtime != test.runtime {
+			t.Errorf("%s: got %q (%d) %d min, want %q (%d) %d min", test.name, e.Title, e.Year, e.Runtime, test.title, test.year, test.runtime)
+		}
+	}
+
+	// Without a provider entries are left alone
+	e := &Entry{Title: "dune", Category: Movie}
+	if m, err := e.Enrich(nil); m != nil || err != nil || e.Title != "dune" {
+		t.Errorf("Enrich(nil) = %v, %v and title %q, want nothing to change", m, err, e.Title)
+	}
+}
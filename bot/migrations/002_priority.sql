@@ -0,0 +1,13 @@
+/*
+Manual ordering of the watchlist
+
+    priority is the 1-based position of an entry in its owner's queue (1 = top)
+    existing entries are numbered in the order they were added
+*/
+ALTER TABLE entries ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+
+UPDATE entries SET priority = (
+    SELECT COUNT(*) FROM entries AS e
+    WHERE e.userID = entries.userID
+      AND (e.date < entries.date OR (e.date = entries.date AND e.rowid <= entries.rowid))
+);
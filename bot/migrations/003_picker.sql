@@ -0,0 +1,32 @@
+/*
+Random picker
+
+    runtime     length of a movie/episode in minutes (0 = unknown), used by the runtime filter
+    tags        free-form labels per entry, used by the tag filter
+    picks       history of random picks, used to avoid repeating recent picks
+*/
+ALTER TABLE entries ADD COLUMN runtime INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS tags (
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    tag         TEXT NOT NULL,
+
+    PRIMARY KEY (userID, title, category, tag)
+);
+
+CREATE TABLE IF NOT EXISTS picks (
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    date        DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS picks_user_date ON picks (userID, date);
+
+-- Tags belong to their entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_tags AFTER DELETE ON entries
+BEGIN
+    DELETE FROM tags WHERE userID = old.userID AND title = old.title AND category = old.category;
+END;
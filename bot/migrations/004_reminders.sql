@@ -0,0 +1,26 @@
+/*
+Reminders
+
+    title/category  entry to remind about, an empty title means "a random unwatched pick"
+    due             next time the reminder fires (UTC)
+    repeat          one of ('', daily, weekly, monthly), reminders without a repeat are deleted once sent
+    dm              true to deliver in a direct message, false to deliver in channelID
+*/
+CREATE TABLE IF NOT EXISTS reminders (
+    reminderID  INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID      TEXT NOT NULL,
+    channelID   TEXT NOT NULL,
+    dm          BOOLEAN NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    due         DATETIME NOT NULL,
+    repeat      TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reminders_due ON reminders (due);
+
+-- Reminders about an entry go away with the entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_reminders AFTER DELETE ON entries
+BEGIN
+    DELETE FROM reminders WHERE userID = old.userID AND title = old.title AND category = old.category;
+END;
@@ -0,0 +1,30 @@
+/*
+Watch parties
+
+    title/category  host's entry being watched
+    start           when the party starts (UTC)
+    duration        expected length in minutes, used to decide when to offer marking the entry done
+    state           one of (scheduled, reminded, ended, done, cancelled)
+    rsvps.status    one of (going, maybe, not_going)
+*/
+CREATE TABLE IF NOT EXISTS parties (
+    partyID     INTEGER PRIMARY KEY AUTOINCREMENT,
+    guildID     TEXT NOT NULL,
+    channelID   TEXT NOT NULL,
+    hostID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    start       DATETIME NOT NULL,
+    duration    INTEGER NOT NULL,
+    state       TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS parties_state_start ON parties (state, start);
+
+CREATE TABLE IF NOT EXISTS rsvps (
+    partyID     INTEGER NOT NULL REFERENCES parties(partyID) ON DELETE CASCADE,
+    userID      TEXT NOT NULL,
+    status      TEXT NOT NULL,
+
+    PRIMARY KEY (partyID, userID)
+);
@@ -0,0 +1,15 @@
+/*
+Per-user settings and UTC timestamps
+
+    users.timezone  IANA timezone name (ex. America/Edmonton), empty for the server's timezone
+
+    dates used to be stored with the server's UTC offset, sqlite's date functions
+    convert any offset to UTC so every stored timestamp compares and sorts the same way
+*/
+CREATE TABLE IF NOT EXISTS users (
+    userID      TEXT PRIMARY KEY,
+    timezone    TEXT NOT NULL DEFAULT ''
+);
+
+UPDATE entries SET date = strftime('%Y-%m-%d %H:%M:%f', date) WHERE strftime('%Y-%m-%d %H:%M:%f', date) IS NOT NULL;
+UPDATE picks SET date = strftime('%Y-%m-%d %H:%M:%f', date) WHERE strftime('%Y-%m-%d %H:%M:%f', date) IS NOT NULL;
@@ -0,0 +1,9 @@
+/*
+Personal API tokens
+
+    tokenHash   hex sha256 of the user's API token, empty if they don't have one
+                (only the hash is stored, the token itself is sent to the user once)
+*/
+ALTER TABLE users ADD COLUMN tokenHash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS users_token ON users (tokenHash);
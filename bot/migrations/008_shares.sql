@@ -0,0 +1,25 @@
+/*
+Share links and guild members
+
+    members     users seen running commands in a guild, used for guild-wide views
+                (the bot doesn't need the privileged members intent this way)
+    shares      unguessable read-only links to a user's watchlist, or to a whole guild's
+                when guildID is set (userID is then the member that created the link)
+*/
+CREATE TABLE IF NOT EXISTS members (
+    guildID     TEXT NOT NULL,
+    userID      TEXT NOT NULL,
+    username    TEXT NOT NULL,
+    lastSeen    DATETIME NOT NULL,
+
+    PRIMARY KEY (guildID, userID)
+);
+
+CREATE TABLE IF NOT EXISTS shares (
+    shareID     TEXT PRIMARY KEY,
+    userID      TEXT NOT NULL,
+    guildID     TEXT NOT NULL,
+    created     DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS shares_user ON shares (userID, guildID);
@@ -0,0 +1,7 @@
+/*
+Completion dates
+
+    doneDate    when the entry was marked done (UTC), NULL if it isn't done or was
+                completed before completion dates were recorded
+*/
+ALTER TABLE entries ADD COLUMN doneDate DATETIME;
@@ -0,0 +1,13 @@
+/*
+Watchlist privacy
+
+    users.privacy   who can see the user's watchlist besides them:
+                    private (nobody), guild (members of servers they use the bot in),
+                    link (guild, plus anyone with one of their share links)
+
+    users that already made a personal share link keep it working
+*/
+ALTER TABLE users ADD COLUMN privacy TEXT NOT NULL DEFAULT 'guild';
+
+INSERT OR IGNORE INTO users(userID) SELECT DISTINCT userID FROM shares WHERE guildID = '';
+UPDATE users SET privacy = 'link' WHERE userID IN (SELECT userID FROM shares WHERE guildID = '');
@@ -0,0 +1,10 @@
+/*
+Metadata filled in from the metadata provider when entries are added
+
+    genres      comma separated, lowercase
+    poster      poster image URL, empty if unknown
+    episodes    number of episodes for shows and anime, 0 if unknown or a movie
+*/
+ALTER TABLE entries ADD COLUMN genres TEXT NOT NULL DEFAULT '';
+ALTER TABLE entries ADD COLUMN poster TEXT NOT NULL DEFAULT '';
+ALTER TABLE entries ADD COLUMN episodes INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,64 @@
+/*
+Release years, so remakes and same-name titles can coexist (ex. Dune 1984 and Dune 2021)
+
+    year        release year, part of the primary key (0 = unknown)
+    externalID  ID from the metadata provider or a recognized link (ex. imdb:tt1160419), empty if unknown
+
+    sqlite can't change a primary key in place, so entries and tags are rebuilt,
+    things that point at an entry (tags, picks, reminders, parties) get a year to match on
+*/
+CREATE TABLE entries_new (
+    userID      TEXT NOT NULL,
+    date        DATETIME NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    externalID  TEXT NOT NULL DEFAULT '',
+    done        BOOLEAN NOT NULL,
+    rating      INTEGER,
+    link        TEXT,
+    priority    INTEGER NOT NULL DEFAULT 0,
+    runtime     INTEGER NOT NULL DEFAULT 0,
+    doneDate    DATETIME,
+    genres      TEXT NOT NULL DEFAULT '',
+    poster      TEXT NOT NULL DEFAULT '',
+    episodes    INTEGER NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (userID, title, category, year)
+);
+
+INSERT INTO entries_new(userID, date, title, category, done, rating, link, priority, runtime, doneDate, genres, poster, episodes)
+SELECT userID, date, title, category, done, rating, link, priority, runtime, doneDate, genres, poster, episodes FROM entries;
+
+-- Dropping the table also drops its triggers, they're recreated below
+DROP TABLE entries;
+ALTER TABLE entries_new RENAME TO entries;
+
+CREATE TABLE tags_new (
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    tag         TEXT NOT NULL,
+
+    PRIMARY KEY (userID, title, category, year, tag)
+);
+
+INSERT INTO tags_new(userID, title, category, tag) SELECT userID, title, category, tag FROM tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+
+ALTER TABLE picks ADD COLUMN year INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE reminders ADD COLUMN year INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE parties ADD COLUMN year INTEGER NOT NULL DEFAULT 0;
+
+-- Tags and reminders belong to their entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_tags AFTER DELETE ON entries
+BEGIN
+    DELETE FROM tags WHERE userID = old.userID AND title = old.title AND category = old.category AND year = old.year;
+END;
+
+CREATE TRIGGER IF NOT EXISTS entries_delete_reminders AFTER DELETE ON entries
+BEGIN
+    DELETE FROM reminders WHERE userID = old.userID AND title = old.title AND category = old.category AND year = old.year;
+END;
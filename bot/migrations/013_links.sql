@@ -0,0 +1,36 @@
+/*
+Any number of links per entry, each with a kind
+
+    links   replaces entries.link
+            kind is one of trailer, info, streaming or other, existing links are sorted into
+            a kind by their site (youtube -> trailer, imdb/letterboxd/myanimelist/trakt -> info)
+*/
+CREATE TABLE IF NOT EXISTS links (
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    url         TEXT NOT NULL,
+    kind        TEXT NOT NULL DEFAULT 'other',
+
+    PRIMARY KEY (userID, title, category, year, url)
+);
+
+INSERT INTO links(userID, title, category, year, url, kind)
+SELECT userID, title, category, year, link,
+    CASE
+        WHEN link LIKE '%youtube.com/%' OR link LIKE '%youtu.be/%' THEN 'trailer'
+        WHEN link LIKE '%imdb.com/%' OR link LIKE '%letterboxd.com/%' OR link LIKE '%myanimelist.net/%' OR link LIKE '%trakt.tv/%' THEN 'info'
+        WHEN link LIKE '%netflix.com/%' OR link LIKE '%primevideo.com/%' OR link LIKE '%disneyplus.com/%' OR link LIKE '%hulu.com/%'
+            OR link LIKE '%max.com/%' OR link LIKE '%crunchyroll.com/%' OR link LIKE '%tv.apple.com/%' THEN 'streaming'
+        ELSE 'other'
+    END
+FROM entries WHERE link IS NOT NULL AND link != '';
+
+ALTER TABLE entries DROP COLUMN link;
+
+-- Links belong to their entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_links AFTER DELETE ON entries
+BEGIN
+    DELETE FROM links WHERE userID = old.userID AND title = old.title AND category = old.category AND year = old.year;
+END;
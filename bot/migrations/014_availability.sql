@@ -0,0 +1,26 @@
+/*
+Where entries can be watched
+
+    service     normalized streaming service name, ex. netflix
+    region      ISO 3166 country code, ex. US, empty if the user didn't say
+    offer       free (with a subscription or ads), rent or buy
+    imported    1 if it came from an availability provider, imports only replace imported rows
+*/
+CREATE TABLE IF NOT EXISTS availability (
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    service     TEXT NOT NULL,
+    region      TEXT NOT NULL DEFAULT '',
+    offer       TEXT NOT NULL DEFAULT 'free',
+    imported    BOOLEAN NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (userID, title, category, year, service, region, offer)
+);
+
+-- Availability belongs to its entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_availability AFTER DELETE ON entries
+BEGIN
+    DELETE FROM availability WHERE userID = old.userID AND title = old.title AND category = old.category AND year = old.year;
+END;
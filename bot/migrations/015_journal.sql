@@ -0,0 +1,19 @@
+/*
+Journal of actions that can be undone
+
+    action      delete, done, rate or update
+    before      JSON of the entry (with its tags, links and availability) before the action
+    undone      1 once the action was undone, so it isn't undone twice
+
+    rows older than the undo window are pruned when new actions are recorded
+*/
+CREATE TABLE IF NOT EXISTS journal (
+    actionID    INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID      TEXT NOT NULL,
+    action      TEXT NOT NULL,
+    date        DATETIME NOT NULL,
+    before      TEXT NOT NULL,
+    undone      BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS journal_user ON journal(userID, actionID);
@@ -0,0 +1,10 @@
+/*
+Soft deletes, deleted entries stay in the trash until they're restored or purged
+
+    deleted     when the entry was deleted (UTC), NULL if it's on the watchlist
+
+    tags, links, availability and reminders are only removed once a trashed entry is purged
+*/
+ALTER TABLE entries ADD COLUMN deleted DATETIME;
+
+CREATE INDEX IF NOT EXISTS entries_deleted ON entries(deleted) WHERE deleted IS NOT NULL;
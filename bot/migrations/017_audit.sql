@@ -0,0 +1,43 @@
+/*
+Append-only audit log of every change to an entry
+
+    actorID     user who made the change, empty for the bot itself, the cli and the admin API token
+    source      where the change came from, one of discord, cli, api or bot
+    guildID     guild the change was made from, empty for DMs, the cli and the API
+    channelID   channel the change was made from, empty outside of discord
+    userID, title, category, year   the entry that changed (its owner is userID)
+    action      add, delete, restore, purge, done, rate, update, runtime, move, tag, untag,
+                link, unlink, stream, unstream, lookup or undo
+    before      JSON of the entry (with its tags, links and availability) before the change, NULL for adds
+    after       JSON of the entry after the change, NULL for purges
+
+    rows are kept forever, the triggers below stop them from being changed or deleted
+*/
+CREATE TABLE IF NOT EXISTS audit (
+    changeID    INTEGER PRIMARY KEY AUTOINCREMENT,
+    date        DATETIME NOT NULL,
+    actorID     TEXT NOT NULL DEFAULT '',
+    source      TEXT NOT NULL,
+    guildID     TEXT NOT NULL DEFAULT '',
+    channelID   TEXT NOT NULL DEFAULT '',
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    action      TEXT NOT NULL,
+    before      TEXT,
+    after       TEXT
+);
+
+CREATE INDEX IF NOT EXISTS audit_entry ON audit(userID, title, category, year);
+CREATE INDEX IF NOT EXISTS audit_guild ON audit(guildID, date);
+
+CREATE TRIGGER IF NOT EXISTS audit_no_update BEFORE UPDATE ON audit
+BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_no_delete BEFORE DELETE ON audit
+BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;
@@ -0,0 +1,30 @@
+/*
+Watch log, every time an entry was watched including rewatches
+
+    date        when it was watched (UTC), NULL if it was watched before dates were recorded
+    rating      the entry's rating for this viewing, 0 if unrated
+    note        optional note about the viewing, ex. "better on the big screen"
+
+    entries that are already done get one viewing on their completion date
+*/
+CREATE TABLE IF NOT EXISTS watches (
+    watchID     INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID      TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0,
+    date        DATETIME,
+    rating      INTEGER NOT NULL DEFAULT 0,
+    note        TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS watches_entry ON watches(userID, title, category, year);
+
+INSERT INTO watches(userID, title, category, year, date, rating)
+SELECT userID, title, category, year, doneDate, COALESCE(rating, 0) FROM entries WHERE done = 1 ORDER BY doneDate;
+
+-- Viewings belong to their entry
+CREATE TRIGGER IF NOT EXISTS entries_delete_watches AFTER DELETE ON entries
+BEGIN
+    DELETE FROM watches WHERE userID = old.userID AND title = old.title AND category = old.category AND year = old.year;
+END;
@@ -0,0 +1,19 @@
+/*
+Adds waiting on the user to decide what to do about a duplicate
+
+    entry       JSON of the entry that was going to be added (with its links)
+    title       title of the existing entry it duplicates
+    category    category of the existing entry
+    year        release year of the existing entry
+
+    rows are deleted once the user decides, and older ones are pruned when new adds are saved
+*/
+CREATE TABLE IF NOT EXISTS pending_adds (
+    pendingID   INTEGER PRIMARY KEY AUTOINCREMENT,
+    userID      TEXT NOT NULL,
+    date        DATETIME NOT NULL,
+    entry       TEXT NOT NULL,
+    title       TEXT NOT NULL,
+    category    TEXT NOT NULL,
+    year        INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,7 @@
+/*
+Reminder delivery failures
+
+    failures    failed attempts at sending the reminder since it came due, it stays due and is retried
+                until REMINDER_MAX_FAILURES is reached
+*/
+ALTER TABLE reminders ADD COLUMN failures INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+/*
+Watch party invites
+
+    messageID   invite message with the RSVP buttons, empty for parties posted before it was kept
+*/
+ALTER TABLE parties ADD COLUMN messageID TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+/*
+Contiguous queue positions
+
+    deleting entries used to leave gaps in their owner's queue, so priorities stopped matching positions
+    every queue is renumbered from 1, keeping its order
+*/
+UPDATE entries SET priority = queue.position
+FROM (
+    SELECT rowid AS id, ROW_NUMBER() OVER (PARTITION BY userID ORDER BY priority, date, rowid) AS position
+    FROM entries WHERE deleted IS NULL
+) AS queue
+WHERE entries.rowid = queue.id;
@@ -0,0 +1,513 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Party represents a scheduled group watch of one of the host's entries
+type Party struct {
+	PartyID   int64           `json:"party_id"`
+	GuildID   string          `json:"guild_id"`
+	ChannelID string          `json:"channel_id"`
+	HostID    string          `json:"host_id"`
+	Title     string          `json:"title"`
+	Category  Category        `json:"category"`
+	Year      int             `json:"year,omitempty"`
+	Start     time.Time       `json:"start"`
+	Duration  int             `json:"duration"` // minutes
+	State     PartyState      `json:"state"`
+	RSVPs     map[string]RSVP `json:"rsvps"`                // userID -> status
+	MessageID string          `json:"message_id,omitempty"` // invite with the RSVP buttons
+}
+
+// PartyState represents where a party is in its lifecycle
+type PartyState string
+
+// RSVP represents an attendee's answer to a party invite
+type RSVP string
+
+const (
+	// Enumerations for party states
+	PARTY_SCHEDULED PartyState = "scheduled" // waiting for the start reminder
+	PARTY_REMINDED  PartyState = "reminded"  // attendees have been reminded, waiting for the party to end
+	PARTY_ENDED     PartyState = "ended"     // host has been offered to mark the entry done
+	PARTY_DONE      PartyState = "done"      // entry has been marked done for attendees
+	PARTY_CANCELLED PartyState = "cancelled" // host cancelled the party
+
+	// Enumerations for RSVPs
+	RSVP_GOING     RSVP = "going"
+	RSVP_MAYBE     RSVP = "maybe"
+	RSVP_NOT_GOING RSVP = "not_going"
+
+	// How long before the start attendees are reminded
+	PARTY_REMINDER_LEAD = 30 * time.Minute
+
+	// Length assumed for entries without a runtime, in minutes
+	DEFAULT_PARTY_DURATION = 120
+
+	// Prefix of the custom IDs of party buttons, ex. party:12:going
+	PARTY_BUTTON_PREFIX = "party"
+)
+
+const PARTY_COLUMNS = "partyID, guildID, channelID, hostID, title, category, year, start, duration, state, messageID"
+
+// Saves a party to the database, sets its ID and RSVPs the host as going
+func (p *Party) Add(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO parties(guildID, channelID, hostID, title, category, year, start, duration, state) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, p.GuildID, p.ChannelID, p.HostID, p.Title, p.Category, p.Year, p.Start.UTC(), p.Duration, p.State)
+	if err != nil {
+		return err
+	}
+
+	if p.PartyID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO rsvps(partyID, userID, status) VALUES(?, ?, ?)", p.PartyID, p.HostID, RSVP_GOING)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	p.RSVPs = map[string]RSVP{p.HostID: RSVP_GOING}
+	slog.Debug("party.Add", "party", p)
+	return nil
+}
+
+/*
+Fetch a party and its RSVPs
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	partyID:	ID of the party
+
+Returns:
+
+	*Party:	ptr to the party
+	error:	PartyNotFoundError or a database error
+*/
+func FetchParty(db *sql.DB, partyID int64) (*Party, error) {
+	parties, err := queryParties(db, "SELECT "+PARTY_COLUMNS+" FROM parties WHERE partyID = ?", partyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(parties) == 0 {
+		return nil, &PartyNotFoundError{partyID}
+	}
+	return parties[0], nil
+}
+
+/*
+Fetch the parties in a guild that haven't started yet, soonest first
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	guildID:	guild to fetch parties for
+
+Returns:
+
+	[]*Party:	upcoming parties
+	error:		error object
+*/
+func FetchUpcomingParties(db *sql.DB, guildID string) ([]*Party, error) {
+	query := "SELECT " + PARTY_COLUMNS + " FROM parties WHERE guildID = ? AND state IN (?, ?) ORDER BY start"
+	return queryParties(db, query, guildID, PARTY_SCHEDULED, PARTY_REMINDED)
+}
+
+/*
+Record a user's RSVP to a party
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	partyID:	ID of the party
+	userID:		user that is responding
+	status:		the user's RSVP
+
+Returns:
+
+	*Party:	ptr to the party with updated RSVPs
+	error:	PartyClosedError if the party is over or cancelled
+*/
+func SetRSVP(db *sql.DB, partyID int64, userID string, status RSVP) (*Party, error) {
+	if err := status.IsValid(); err != nil {
+		return nil, err
+	}
+
+	party, err := FetchParty(db, partyID)
+	if err != nil {
+		return nil, err
+	}
+	if party.State == PARTY_DONE || party.State == PARTY_CANCELLED {
+		return nil, &PartyClosedError{partyID, party.State}
+	}
+
+	query := "INSERT INTO rsvps(partyID, userID, status) VALUES(?, ?, ?) ON CONFLICT(partyID, userID) DO UPDATE SET status = excluded.status"
+	if _, err = db.Exec(query, partyID, userID, status); err != nil {
+		return nil, err
+	}
+
+	party.RSVPs[userID] = status
+	slog.Debug("party.SetRSVP", "party", partyID, "user", userID, "status", status)
+	return party, nil
+}
+
+/*
+Cancel a party, only the host can cancel
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user cancelling the party
+	partyID:	ID of the party
+
+Returns:
+
+	*Party:	ptr to the cancelled party
+	error:	NotPartyHostError, PartyClosedError or a database error
+*/
+func CancelParty(db *sql.DB, userID string, partyID int64) (*Party, error) {
+	party, err := FetchParty(db, partyID)
+	if err != nil {
+		return nil, err
+	}
+	if party.HostID != userID {
+		return nil, &NotPartyHostError{userID, partyID}
+	}
+	if party.State == PARTY_DONE || party.State == PARTY_CANCELLED {
+		return nil, &PartyClosedError{partyID, party.State}
+	}
+
+	if err = party.setState(db, PARTY_CANCELLED); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("party.CancelParty", "party", partyID, "user", userID)
+	return party, nil
+}
+
+/*
+Mark the party's entry as done for everyone who attended, only the host can finish a party
+
+Attendees are everyone who RSVP'd going, attendees without the entry on their watchlist (or with several entries it could be) are skipped.
+Either the entry is marked done for every attendee and the party is closed, or nothing changes.
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is finishing the party
+	partyID:	ID of the party
+
+Returns:
+
+	*Party:		ptr to the finished party
+	[]string:	user IDs the entry was marked done for
+	error:		NotPartyHostError, PartyClosedError or a database error
+*/
+func FinishParty(db *sql.DB, actor *Actor, partyID int64) (*Party, []string, error) {
+	party, err := FetchParty(db, partyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if party.HostID != actor.UserID {
+		return nil, nil, &NotPartyHostError{actor.UserID, partyID}
+	}
+	if party.State == PARTY_DONE || party.State == PARTY_CANCELLED {
+		return nil, nil, &PartyClosedError{partyID, party.State}
+	}
+
+	// Find every attendee's entry before writing anything
+	var (
+		entries []*Entry
+		marked  []string
+	)
+	for _, attendee := range party.attendees(RSVP_GOING) {
+		entry, err := findEntryWithDetails(db, attendee, party.Title, party.Category, party.Year)
+		var (
+			notFound  *EntryNotFoundError
+			ambiguous *AmbiguousEntryError
+		)
+		if errors.As(err, &notFound) || errors.As(err, &ambiguous) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry)
+		marked = append(marked, attendee)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if err = entry.done(tx, actor, ""); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err = party.setState(tx, PARTY_DONE); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	slog.Debug("party.FinishParty", "party", partyID, "marked", marked)
+	return party, marked, nil
+}
+
+/*
+Remind attendees of parties that are about to start, and offer hosts of
+parties that have ended to mark the entry done for attendees
+
+Runs as a scheduler job on every tick.
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	s:		ptr to discord session
+	now:	current time
+*/
+func runParties(db *sql.DB, s *discordgo.Session, now time.Time) error {
+	// Parties starting soon
+	query := "SELECT " + PARTY_COLUMNS + " FROM parties WHERE state = ? AND start <= ?"
+	starting, err := queryParties(db, query, PARTY_SCHEDULED, now.Add(PARTY_REMINDER_LEAD).UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range starting {
+		mentions := mentionAll(append(p.attendees(RSVP_GOING), p.attendees(RSVP_MAYBE)...))
+		message := fmt.Sprintf("%s watch party for **%s** starts <t:%d:R>", mentions, p.DisplayTitle(), p.Start.Unix())
+		if _, err := s.ChannelMessageSend(p.ChannelID, message); err != nil {
+			slog.Error("party.runParties", "party", p.PartyID, "msg", err)
+		}
+		if err := p.setState(db, PARTY_REMINDED); err != nil {
+			return err
+		}
+	}
+
+	// Parties that have started, the end time depends on each party's duration
+	started, err := queryParties(db, query, PARTY_REMINDED, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, p := range started {
+		if p.Start.Add(time.Duration(p.Duration) * time.Minute).After(now) {
+			continue
+		}
+
+		message := &discordgo.MessageSend{
+			Content: fmt.Sprintf("<@%s> the watch party for **%s** is over, mark it as done for everyone who went?", p.HostID, p.DisplayTitle()),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: "Mark done", Style: discordgo.SuccessButton, CustomID: p.buttonID("done")},
+				}},
+			},
+		}
+		if _, err := s.ChannelMessageSendComplex(p.ChannelID, message); err != nil {
+			slog.Error("party.runParties", "party", p.PartyID, "msg", err)
+		}
+		if err := p.setState(db, PARTY_ENDED); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Embedded message showing the party and its RSVPs
+func (p *Party) Embed() *discordgo.MessageEmbed {
+	status := ""
+	if p.State == PARTY_CANCELLED {
+		status = " (cancelled)"
+	}
+
+	field := func(name string, status RSVP) *discordgo.MessageEmbedField {
+		attendees := p.attendees(status)
+		value := mentionAll(attendees)
+		if value == "" {
+			value = "-"
+		}
+		return &discordgo.MessageEmbedField{Name: fmt.Sprintf("%s (%d)", name, len(attendees)), Value: value, Inline: true}
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("Watch party #%d: %s%s", p.PartyID, p.DisplayTitle(), status),
+		// Discord renders <t:unix:F> in each viewer's own timezone
+		Description: fmt.Sprintf("hosted by <@%s>\n<t:%d:F> (<t:%d:R>)", p.HostID, p.Start.Unix(), p.Start.Unix()),
+		Fields: []*discordgo.MessageEmbedField{
+			field("Going", RSVP_GOING),
+			field("Maybe", RSVP_MAYBE),
+			field("Not going", RSVP_NOT_GOING),
+		},
+	}
+}
+
+// RSVP buttons shown under the party embed, disabled once the party is closed
+func (p *Party) Components() []discordgo.MessageComponent {
+	closed := p.State == PARTY_DONE || p.State == PARTY_CANCELLED
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Going", Style: discordgo.SuccessButton, CustomID: p.buttonID(string(RSVP_GOING)), Disabled: closed},
+			discordgo.Button{Label: "Maybe", Style: discordgo.SecondaryButton, CustomID: p.buttonID(string(RSVP_MAYBE)), Disabled: closed},
+			discordgo.Button{Label: "Not going", Style: discordgo.DangerButton, CustomID: p.buttonID(string(RSVP_NOT_GOING)), Disabled: closed},
+		}},
+	}
+}
+
+// Title of the party's entry with its release year when known
+func (p *Party) DisplayTitle() string {
+	return displayTitle(p.Title, p.Year)
+}
+
+// Custom ID of a party button, ex. party:12:going
+func (p *Party) buttonID(action string) string {
+	return fmt.Sprintf("%s:%d:%s", PARTY_BUTTON_PREFIX, p.PartyID, action)
+}
+
+// User IDs with the given RSVP, in a stable order
+func (p *Party) attendees(status RSVP) []string {
+	var users []string
+	for userID, rsvp := range p.RSVPs {
+		if rsvp == status {
+			users = append(users, userID)
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+/*
+Remember the invite message of a party, so it can be updated when the party is closed
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	messageID:	ID of the invite message, in the party's channel
+*/
+func (p *Party) SetMessage(db *sql.DB, messageID string) error {
+	if _, err := db.Exec("UPDATE parties SET messageID = ? WHERE partyID = ?", messageID, p.PartyID); err != nil {
+		return err
+	}
+	p.MessageID = messageID
+	return nil
+}
+
+// Update the state of a party in the database
+func (p *Party) setState(db execer, state PartyState) error {
+	if _, err := db.Exec("UPDATE parties SET state = ? WHERE partyID = ?", state, p.PartyID); err != nil {
+		return err
+	}
+	p.State = state
+	return nil
+}
+
+// Run a party query, scan the results and load their RSVPs
+func queryParties(db *sql.DB, query string, args ...any) ([]*Party, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var parties []*Party
+	for rows.Next() {
+		p := &Party{RSVPs: make(map[string]RSVP)}
+		err := rows.Scan(&p.PartyID, &p.GuildID, &p.ChannelID, &p.HostID, &p.Title, &p.Category, &p.Year, &p.Start, &p.Duration, &p.State, &p.MessageID)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		parties = append(parties, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load RSVPs after closing the party rows so the queries don't hold two connections at once
+	for _, p := range parties {
+		rows, err := db.Query("SELECT userID, status FROM rsvps WHERE partyID = ?", p.PartyID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				userID string
+				status RSVP
+			)
+			if err := rows.Scan(&userID, &status); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			p.RSVPs[userID] = status
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return parties, nil
+}
+
+// Mention every user in a list, ex. <@1> <@2>
+func mentionAll(userIDs []string) string {
+	mentions := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = fmt.Sprintf("<@%s>", userID)
+	}
+	return strings.Join(mentions, " ")
+}
+
+// enum validation
+func (r *RSVP) IsValid() error {
+	switch *r {
+	case RSVP_GOING, RSVP_MAYBE, RSVP_NOT_GOING:
+		return nil
+	default:
+		return &InvalidRSVPError{r}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFinishParty(t *testing.T) {
+	db := newTestDB(t)
+
+	attendees := []struct {
+		userID   string
+		rsvp     RSVP
+		hasEntry bool
+		wantDone bool
+	}{
+		{"1", RSVP_GOING, true, true}, // host
+		{"2", RSVP_GOING, true, true},
+		{"3", RSVP_GOING, false, false}, // going without the entry on their watchlist
+		{"4", RSVP_MAYBE, true, false},
+		{"5", RSVP_NOT_GOING, true, false},
+	}
+	for _, a := range attendees {
+		if a.hasEntry {
+			addTestEntries(t, db, &Entry{UserID: a.userID, Title: "Alien", Category: Movie})
+		}
+	}
+
+	party := &Party{GuildID: "g", ChannelID: "c", HostID: "1", Title: "Alien", Category: Movie,
+		Start: time.Now(), Duration: DEFAULT_PARTY_DURATION, State: PARTY_ENDED}
+	if err := party.Add(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range attendees[1:] {
+		if _, err := SetRSVP(db, party.PartyID, a.userID, a.rsvp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Going with two releases of the title, so there's no telling which one was watched
+	addTestEntries(t, db,
+		&Entry{UserID: "6", Title: "Alien", Category: Movie, Year: 1979},
+		&Entry{UserID: "6", Title: "Alien", Category: Movie, Year: 2003},
+	)
+	if _, err := SetRSVP(db, party.PartyID, "6", RSVP_GOING); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the host can finish it
+	var notHost *NotPartyHostError
+	if _, _, err := FinishParty(db, &Actor{UserID: "2", Source: SOURCE_DISCORD}, party.PartyID); !errors.As(err, &notHost) {
+		t.Fatalf("finishing as a guest: got %v, want NotPartyHostError", err)
+	}
+
+	party, marked, err := FinishParty(db, &Actor{UserID: "1", Source: SOURCE_DISCORD}, party.PartyID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if party.State != PARTY_DONE {
+		t.Errorf("got state %s, want %s", party.State, PARTY_DONE)
+	}
+	if len(marked) != 2 || marked[0] != "1" || marked[1] != "2" {
+		t.Errorf("marked %v, want [1 2]", marked)
+	}
+
+	for _, a := range attendees {
+		if !a.hasEntry {
+			continue
+		}
+		entry, err := FindEntry(db, ADMIN_VIEWER, a.userID, "Alien", Movie, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Done != a.wantDone {
+			t.Errorf("user %s (%s): done is %v, want %v", a.userID, a.rsvp, entry.Done, a.wantDone)
+		}
+	}
+
+	for _, year := range []int{1979, 2003} {
+		if entry, err := FindEntry(db, ADMIN_VIEWER, "6", "Alien", Movie, year); err != nil || entry.Done {
+			t.Errorf("user 6 Alien (%d): got %v, %v, want it skipped", year, entry, err)
+		}
+	}
+
+	// Closed parties can't be finished again
+	var closed *PartyClosedError
+	if _, _, err := FinishParty(db, &Actor{UserID: "1", Source: SOURCE_DISCORD}, party.PartyID); !errors.As(err, &closed) {
+		t.Errorf("finishing twice: got %v, want PartyClosedError", err)
+	}
+}
+
+func TestFinishCancelledParty(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db, &Entry{UserID: "1", Title: "Alien", Category: Movie})
+
+	party := &Party{GuildID: "g", ChannelID: "c", HostID: "1", Title: "Alien", Category: Movie,
+		Start: time.Now().Add(time.Hour), Duration: DEFAULT_PARTY_DURATION, State: PARTY_SCHEDULED}
+	if err := party.Add(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CancelParty(db, "1", party.PartyID); err != nil {
+		t.Fatal(err)
+	}
+
+	var closed *PartyClosedError
+	if _, _, err := FinishParty(db, &Actor{UserID: "1", Source: SOURCE_DISCORD}, party.PartyID); !errors.As(err, &closed) {
+		t.Errorf("got %v, want PartyClosedError", err)
+	}
+	if entry, err := FindEntry(db, ADMIN_VIEWER, "1", "Alien", Movie, 0); err != nil || entry.Done {
+		t.Errorf("got %v, %v, want the entry left unwatched", entry, err)
+	}
+}
+
+// RSVPs go with their party, which needs foreign keys turned on for every connection
+func TestPartyRSVPsCascade(t *testing.T) {
+	db := newTestDB(t)
+
+	party := &Party{GuildID: "g", ChannelID: "c", HostID: "1", Title: "Alien", Category: Movie,
+		Start: time.Now(), Duration: DEFAULT_PARTY_DURATION, State: PARTY_SCHEDULED}
+	if err := party.Add(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SetRSVP(db, party.PartyID, "2", RSVP_GOING); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec("DELETE FROM parties WHERE partyID = ?", party.PartyID); err != nil {
+		t.Fatal(err)
+	}
+	var rsvps int
+	if err := db.QueryRow("SELECT COUNT(*) FROM rsvps").Scan(&rsvps); err != nil {
+		t.Fatal(err)
+	}
+	if rsvps != 0 {
+		t.Errorf("%d RSVPs left for a deleted party, want 0", rsvps)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Weighting decides how likely each entry is to be picked
+type Weighting string
+
+const (
+	// Enumerations for weighting random picks
+	WEIGHT_NONE     Weighting = "none"     // every entry is equally likely
+	WEIGHT_AGE      Weighting = "age"      // entries that have been on the list longer are more likely
+	WEIGHT_PRIORITY Weighting = "priority" // entries near the top of the queue are more likely
+
+	// Discord allows at most 10 embeds per message, one embed is sent per pick
+	MAX_PICK_COUNT = 10
+
+	// Picks kept per user in the pick history, so the most skip:<n> can look back
+	MAX_PICK_MEMORY = 100
+)
+
+// PickOptions holds the filters and settings for a random pick
+type PickOptions struct {
+	Category   Category  // only pick entries of this category (empty = any)
+	Tag        string    // only pick entries with this tag (empty = any)
+	MaxRuntime int       // only pick entries at most this many minutes long (0 = any)
+	Service    string    // only pick entries available on this service (empty = any)
+	Region     string    // region Service has to be available in (empty = any)
+	Weighting  Weighting // how to weight entries
+	Count      int       // number of distinct entries to pick
+	Memory     int       // skip entries that were among the last Memory picks (at most MAX_PICK_MEMORY)
+}
+
+/*
+Parse the arguments of the random command into pick options
+
+Options are given as key:value pairs in any order, for backwards compatibility
+"top" is shorthand for weight:priority and a bare category filters by category
+
+Params:
+
+	args:	arguments after the random command, ex. []string{"movie", "tag:horror", "count:3"}
+
+Returns:
+
+	*PickOptions:	ptr to the parsed options
+	error:			error object
+*/
+func ParsePickOptions(args []string) (*PickOptions, error) {
+	opts := &PickOptions{Weighting: WEIGHT_NONE, Count: 1}
+
+	for _, arg := range args {
+		key, value, found := strings.Cut(strings.ToLower(arg), ":")
+
+		// Shorthands without a key
+		if !found {
+			if key == "top" {
+				opts.Weighting = WEIGHT_PRIORITY
+				continue
+			}
+			key, value = "category", key
+		}
+
+		var err error
+		switch key {
+		case "category":
+			opts.Category = Category(value)
+			err = opts.Category.IsValid()
+		case "tag":
+			opts.Tag = value
+		case "runtime":
+			opts.MaxRuntime, err = strconv.Atoi(value)
+			if err == nil && opts.MaxRuntime <= 0 {
+				return nil, &InvalidPickOptionError{arg}
+			}
+		case "on":
+			opts.Service, opts.Region = ParseServiceFilter(value)
+		case "weight":
+			opts.Weighting = Weighting(value)
+			err = opts.Weighting.IsValid()
+		case "count":
+			opts.Count, err = strconv.Atoi(value)
+		case "skip":
+			opts.Memory, err = strconv.Atoi(value)
+		default:
+			return nil, &InvalidPickOptionError{arg}
+		}
+
+		if err != nil {
+			return nil, &InvalidPickOptionError{arg}
+		}
+	}
+
+	opts.Count = max(1, min(opts.Count, MAX_PICK_COUNT))
+	opts.Memory = max(0, min(opts.Memory, MAX_PICK_MEMORY))
+	return opts, nil
+}
+
+/*
+Pick random unwatched entries from a user's watchlist
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	userID:	user ID to pick entries for
+	opts:	filters and settings for the pick
+
+Returns:
+
+	[]*Entry:	up to opts.Count distinct entries
+	error:		EmptyWatchlistError, NoMatchingEntriesError or a database error
+*/
+func Pick(db *sql.DB, userID string, opts *PickOptions) ([]*Entry, error) {
+	unwatched, err := fetchWatchlist(db, userID, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(unwatched.Entries) == 0 {
+		return nil, &EmptyWatchlistError{userID}
+	}
+
+	recent, err := recentPicks(db, userID, opts.Memory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Narrow down to entries that match every filter
+	var candidates []*Entry
+	for _, e := range unwatched.Entries {
+		if opts.matches(e) && !recent[e.mapKey()] {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, &NoMatchingEntriesError{userID}
+	}
+
+	// Weighted sampling without replacement
+	weights := opts.weights(candidates)
+	var picks []*Entry
+	for len(picks) < opts.Count && len(candidates) > 0 {
+		i := weightedIndex(weights)
+		picks = append(picks, candidates[i])
+		candidates = append(candidates[:i], candidates[i+1:]...)
+		weights = append(weights[:i], weights[i+1:]...)
+	}
+
+	if err = recordPicks(db, userID, picks); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("picker.Pick", "user", userID, "options", opts, "picks", picks)
+	return picks, nil
+}
+
+// Check if an entry passes the pick filters
+//
+// Entries with an unknown runtime never pass a runtime filter, entries with no availability never pass a service filter
+func (opts *PickOptions) matches(e *Entry) bool {
+	if opts.Category != "" && e.Category != opts.Category {
+		return false
+	}
+	if opts.Tag != "" && !e.HasTag(opts.Tag) {
+		return false
+	}
+	if opts.MaxRuntime > 0 && (e.Runtime == 0 || e.Runtime > opts.MaxRuntime) {
+		return false
+	}
+	if opts.Service != "" && !e.AvailableOn(opts.Service, opts.Region) {
+		return false
+	}
+	return true
+}
+
+// Weights for each candidate, in the same order as the candidates
+func (opts *PickOptions) weights(candidates []*Entry) []float64 {
+	weights := make([]float64, len(candidates))
+
+	switch opts.Weighting {
+	case WEIGHT_AGE:
+		// +1 so entries added today can still be picked
+		for i, e := range candidates {
+			weights[i] = time.Since(e.Date).Hours()/24 + 1
+		}
+
+	case WEIGHT_PRIORITY:
+		// Top candidate gets weight n, bottom candidate gets weight 1
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Priority < candidates[j].Priority
+		})
+		for i := range candidates {
+			weights[i] = float64(len(candidates) - i)
+		}
+
+	default:
+		for i := range candidates {
+			weights[i] = 1
+		}
+	}
+
+	return weights
+}
+
+// Pick an index with probability proportional to its weight
+func weightedIndex(weights []float64) int {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	roll := rand.Float64() * total
+	for i, w := range weights {
+		roll -= w
+		if roll < 0 {
+			return i
+		}
+	}
+	return len(weights) - 1
+}
+
+/*
+Fetch the most recent picks for a user
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	userID:	user ID to fetch picks for
+	n:		number of picks to fetch
+
+Returns:
+
+	map[entryKey]bool:	set of the key of each picked entry
+	error:				error object
+*/
+func recentPicks(db *sql.DB, userID string, n int) (map[entryKey]bool, error) {
+	recent := make(map[entryKey]bool)
+	if n == 0 {
+		return recent, nil
+	}
+
+	rows, err := db.Query("SELECT title, category, year FROM picks WHERE userID = ? ORDER BY date DESC, rowid DESC LIMIT ?", userID, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &Entry{UserID: userID}
+		if err := rows.Scan(&e.Title, &e.Category, &e.Year); err != nil {
+			return nil, err
+		}
+		recent[e.mapKey()] = true
+	}
+
+	return recent, rows.Err()
+}
+
+// Save picks to the pick history, and forget the user's picks that are too old to be skipped
+func recordPicks(db *sql.DB, userID string, picks []*Entry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, e := range picks {
+		_, err := tx.Exec("INSERT INTO picks(userID, title, category, year, date) VALUES(?, ?, ?, ?, ?)", userID, e.Title, e.Category, e.Year, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := "DELETE FROM picks WHERE userID = ? AND rowid NOT IN (SELECT rowid FROM picks WHERE userID = ? ORDER BY date DESC, rowid DESC LIMIT ?)"
+	if _, err = tx.Exec(query, userID, userID, MAX_PICK_MEMORY); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// enum validation
+func (w *Weighting) IsValid() error {
+	switch *w {
+	case WEIGHT_NONE, WEIGHT_AGE, WEIGHT_PRIORITY:
+		return nil
+	default:
+		return &InvalidWeightingError{w}
+	}
+}
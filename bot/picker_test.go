@@ -0,0 +1,270 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePickOptions(t *testing.T) {
+	tests := []struct {
+		args []string
+		want *PickOptions
+	}{
+		{nil, &PickOptions{Weighting: WEIGHT_NONE, Count: 1}},
+		{[]string{"top"}, &PickOptions{Weighting: WEIGHT_PRIORITY, Count: 1}},
+		{[]string{"movie"}, &PickOptions{Category: Movie, Weighting: WEIGHT_NONE, Count: 1}},
+		{[]string{"category:anime", "weight:age"}, &PickOptions{Category: Anime, Weighting: WEIGHT_AGE, Count: 1}},
+		{[]string{"tag:Horror", "runtime:90"}, &PickOptions{Tag: "horror", MaxRuntime: 90, Weighting: WEIGHT_NONE, Count: 1}},
+		{[]string{"on:Disney+:ca"}, &PickOptions{Service: "disney", Region: "CA", Weighting: WEIGHT_NONE, Count: 1}},
+		{[]string{"count:3", "skip:5"}, &PickOptions{Weighting: WEIGHT_NONE, Count: 3, Memory: 5}},
+
+		// Counts and memory are clamped
+		{[]string{"count:50"}, &PickOptions{Weighting: WEIGHT_NONE, Count: MAX_PICK_COUNT}},
+		{[]string{"count:0", "skip:-2"}, &PickOptions{Weighting: WEIGHT_NONE, Count: 1}},
+		{[]string{"skip:1000"}, &PickOptions{Weighting: WEIGHT_NONE, Count: 1, Memory: MAX_PICK_MEMORY}},
+	}
+	for _, test := range tests {
+		got, err := ParsePickOptions(test.args)
+		if err != nil {
+			t.Errorf("ParsePickOptions(%q): %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParsePickOptions(%q) = %+v, want %+v", test.args, got, test.want)
+		}
+	}
+}
+
+func TestParsePickOptionsInvalid(t *testing.T) {
+	tests := [][]string{
+		{"podcast"},
+		{"category:podcast"},
+		{"weight:heavy"},
+		{"count:three"},
+		{"runtime:long"},
+		{"runtime:0"},
+		{"runtime:-90"},
+		{"skip:x"},
+		{"colour:red"},
+	}
+	for _, args := range tests {
+		var invalid *InvalidPickOptionError
+		if _, err := ParsePickOptions(args); !errors.As(err, &invalid) {
+			t.Errorf("ParsePickOptions(%q): got %v, want InvalidPickOptionError", args, err)
+		}
+	}
+}
+
+func TestPickOptionsMatches(t *testing.T) {
+	entry := &Entry{
+		Title:        "Alien",
+		Category:     Movie,
+		Runtime:      117,
+		Tags:         []string{"horror", "sci-fi"},
+		Availability: []*Availability{{Service: "disney", Region: "CA", Offer: OFFER_FREE}},
+	}
+	unknownRuntime := &Entry{Title: "Heat", Category: Movie}
+
+	tests := []struct {
+		opts  PickOptions
+		entry *Entry
+		want  bool
+	}{
+		{PickOptions{}, entry, true},
+		{PickOptions{Category: Movie}, entry, true},
+		{PickOptions{Category: Show}, entry, false},
+		{PickOptions{Tag: "HORROR"}, entry, true},
+		{PickOptions{Tag: "comedy"}, entry, false},
+		{PickOptions{MaxRuntime: 120}, entry, true},
+		{PickOptions{MaxRuntime: 117}, entry, true},
+		{PickOptions{MaxRuntime: 90}, entry, false},
+		{PickOptions{MaxRuntime: 120}, unknownRuntime, false},
+		{PickOptions{Service: "disney"}, entry, true},
+		{PickOptions{Service: "disney", Region: "CA"}, entry, true},
+		{PickOptions{Service: "disney", Region: "US"}, entry, false},
+		{PickOptions{Service: "netflix"}, entry, false},
+		{PickOptions{Service: "netflix"}, unknownRuntime, false},
+		{PickOptions{Category: Movie, Tag: "sci-fi", MaxRuntime: 150, Service: "disney"}, entry, true},
+	}
+	for _, test := range tests {
+		if got := test.opts.matches(test.entry); got != test.want {
+			t.Errorf("%+v matches %s = %v, want %v", test.opts, test.entry.Title, got, test.want)
+		}
+	}
+}
+
+func TestPickOptionsWeights(t *testing.T) {
+	now := time.Now()
+	candidates := func() []*Entry {
+		return []*Entry{
+			{Title: "c", Priority: 3, Date: now.AddDate(0, 0, -1)},
+			{Title: "a", Priority: 1, Date: now.AddDate(0, 0, -10)},
+			{Title: "b", Priority: 2, Date: now},
+		}
+	}
+
+	tests := []struct {
+		weighting Weighting
+		order     []string // candidate titles after weighting, weights line up with them
+		want      []float64
+	}{
+		{WEIGHT_NONE, []string{"c", "a", "b"}, []float64{1, 1, 1}},
+		{WEIGHT_PRIORITY, []string{"a", "b", "c"}, []float64{3, 2, 1}},
+		{WEIGHT_AGE, []string{"c", "a", "b"}, []float64{2, 11, 1}},
+	}
+	for _, test := range tests {
+		entries := candidates()
+		opts := &PickOptions{Weighting: test.weighting}
+		weights := opts.weights(entries)
+
+		for i, e := range entries {
+			if e.Title != test.order[i] {
+				t.Errorf("%s: candidate %d is %s, want %s", test.weighting, i, e.Title, test.order[i])
+			}
+			// Ages are measured from time.Now, allow for the time the test takes
+			if diff := weights[i] - test.want[i]; diff < -0.01 || diff > 0.01 {
+				t.Errorf("%s: weight of %s is %.2f, want %.2f", test.weighting, e.Title, weights[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestWeightedIndex(t *testing.T) {
+	tests := []struct {
+		weights []float64
+		want    int
+	}{
+		{[]float64{1}, 0},
+		{[]float64{0, 0, 5}, 2},
+		{[]float64{5, 0, 0}, 0},
+		{[]float64{0, 2, 0}, 1},
+	}
+	for _, test := range tests {
+		// Only one index has any weight, so every roll lands on it
+		for range 100 {
+			if got := weightedIndex(test.weights); got != test.want {
+				t.Fatalf("weightedIndex(%v) = %d, want %d", test.weights, got, test.want)
+			}
+		}
+	}
+
+	// Heavier weights come up more often
+	counts := make([]int, 2)
+	for range 10000 {
+		counts[weightedIndex([]float64{1, 9})]++
+	}
+	if counts[1] < 8000 || counts[1] > 9800 {
+		t.Errorf("weightedIndex([1 9]) picked index 1 %d/10000 times, want about 9000", counts[1])
+	}
+}
+
+func TestPick(t *testing.T) {
+	db := newTestDB(t)
+
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "1", Title: "Heat", Category: Movie},
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+	)
+	if _, err := DoneEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Watched entries are never picked, and picks are distinct
+	picks, err := Pick(db, "1", &PickOptions{Weighting: WEIGHT_NONE, Count: MAX_PICK_COUNT})
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make(map[string]bool)
+	for _, e := range picks {
+		titles[e.Title] = true
+	}
+	if len(picks) != 2 || !titles["Alien"] || !titles["Heat"] {
+		t.Errorf("got picks %v, want Alien and Heat once each", picks)
+	}
+
+	// Skipping recent picks leaves whichever wasn't picked last
+	last, err := Pick(db, "1", &PickOptions{Weighting: WEIGHT_NONE, Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := Pick(db, "1", &PickOptions{Weighting: WEIGHT_NONE, Count: 2, Memory: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 1 || next[0].Title == last[0].Title {
+		t.Errorf("picked %v after %s with skip:1, want only the other entry", next, last[0].Title)
+	}
+
+	// Nothing left to pick
+	errs := []struct {
+		userID string
+		opts   *PickOptions
+		want   any
+	}{
+		{"2", &PickOptions{Weighting: WEIGHT_NONE, Count: 1}, &EmptyWatchlistError{}},
+		{"1", &PickOptions{Category: Anime, Weighting: WEIGHT_NONE, Count: 1}, &NoMatchingEntriesError{}},
+		{"1", &PickOptions{Weighting: WEIGHT_NONE, Count: 1, Memory: 3}, &NoMatchingEntriesError{}},
+	}
+	for _, test := range errs {
+		_, err := Pick(db, test.userID, test.opts)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.want) {
+			t.Errorf("Pick(%s, %+v): got %v, want %T", test.userID, test.opts, err, test.want)
+		}
+	}
+}
+
+func TestRecordPicksPrunes(t *testing.T) {
+	db := newTestDB(t)
+
+	// Two more picks than are kept, in one call so they share a date
+	picks := make([]*Entry, MAX_PICK_MEMORY+2)
+	for i := range picks {
+		picks[i] = &Entry{Title: fmt.Sprintf("Entry %d", i), Category: Movie}
+	}
+	if err := recordPicks(db, "1", picks); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordPicks(db, "2", picks[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	recent, err := recentPicks(db, "1", MAX_PICK_MEMORY+2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != MAX_PICK_MEMORY {
+		t.Errorf("kept %d picks, want %d", len(recent), MAX_PICK_MEMORY)
+	}
+	for _, e := range picks[:2] {
+		if recent[e.mapKey()] {
+			t.Errorf("oldest pick %s was kept", e.Title)
+		}
+	}
+
+	// Other users' history is left alone
+	if recent, err = recentPicks(db, "2", MAX_PICK_MEMORY); err != nil || len(recent) != 1 {
+		t.Errorf("user 2 has %d picks (%v), want 1", len(recent), err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Privacy is who can see a user's watchlist, each level includes the ones before it
+type Privacy string
+
+const (
+	// Enumerations for privacy settings
+	PRIVACY_PRIVATE Privacy = "private" // only the owner
+	PRIVACY_GUILD   Privacy = "guild"   // members of servers the owner has used the bot in
+	PRIVACY_LINK    Privacy = "link"    // also anyone with one of the owner's share links
+
+	DEFAULT_PRIVACY = PRIVACY_GUILD
+)
+
+// Privacy levels from most to least private
+var privacyLevels = []Privacy{PRIVACY_PRIVATE, PRIVACY_GUILD, PRIVACY_LINK}
+
+func (p *Privacy) IsValid() error {
+	switch *p {
+	case PRIVACY_PRIVATE, PRIVACY_GUILD, PRIVACY_LINK:
+		return nil
+	}
+	return &InvalidPrivacyError{*p}
+}
+
+// Check whether a watchlist with this privacy can be shown to an audience (PRIVACY_GUILD or PRIVACY_LINK)
+func (p Privacy) allows(audience Privacy) bool {
+	for _, level := range privacyLevels {
+		if level == audience {
+			return true
+		}
+		if level == p {
+			return false
+		}
+	}
+	return false
+}
+
+// Viewer is someone reading a watchlist
+type Viewer struct {
+	UserID  string // empty for visitors through a share link
+	GuildID string // guild the watchlist is viewed from, empty for DMs and share links
+	Admin   bool   // the bot's operator (cli, admin API token), sees everything
+}
+
+// The bot's operator, for reads that aren't made on behalf of a user
+var ADMIN_VIEWER = &Viewer{Admin: true}
+
+/*
+Check whether a viewer can see a user's watchlist
+
+Viewers in a guild can see the watchlists of members of that guild, viewers outside
+a guild (ex. in DMs or through the API) those of members of any guild they share
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	viewer:		who is reading the watchlist
+	ownerID:	owner of the watchlist
+
+Returns:
+
+	error:	PrivateWatchlistError if the viewer can't see it, or a database error
+*/
+func CanView(db *sql.DB, viewer *Viewer, ownerID string) error {
+	if viewer.Admin || (viewer.UserID != "" && viewer.UserID == ownerID) {
+		return nil
+	}
+
+	owner, err := FetchUser(db, ownerID)
+	if err != nil {
+		return err
+	}
+
+	// Share link visitors
+	if viewer.UserID == "" {
+		if owner.Privacy.allows(PRIVACY_LINK) {
+			return nil
+		}
+		return &PrivateWatchlistError{ownerID}
+	}
+
+	if !owner.Privacy.allows(PRIVACY_GUILD) {
+		return &PrivateWatchlistError{ownerID}
+	}
+
+	query := "SELECT COUNT(*) FROM members a JOIN members b ON a.guildID = b.guildID WHERE a.userID = ? AND b.userID = ?"
+	args := []any{viewer.UserID, ownerID}
+	if viewer.GuildID != "" {
+		query += " AND a.guildID = ?"
+		args = append(args, viewer.GuildID)
+	}
+
+	var shared int
+	if err := db.QueryRow(query, args...).Scan(&shared); err != nil {
+		return err
+	}
+	if shared == 0 {
+		return &PrivateWatchlistError{ownerID}
+	}
+	return nil
+}
+
+// Subquery selecting the members of a guild whose entries can be shown to an audience, takes the guild ID as its only parameter
+func guildUsersQuery(audience Privacy) string {
+	var allowed []string
+	for _, level := range privacyLevels {
+		if level.allows(audience) {
+			allowed = append(allowed, "'"+string(level)+"'")
+		}
+	}
+
+	return "SELECT m.userID FROM members m LEFT JOIN users u ON u.userID = m.userID " +
+		"WHERE m.guildID = ? AND COALESCE(u.privacy, '" + string(DEFAULT_PRIVACY) + "') IN (" + strings.Join(allowed, ", ") + ")"
+}
+
+/*
+Set who can see the user's watchlist
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	privacy:	new privacy setting
+
+Returns:
+
+	error:	InvalidPrivacyError or a database error
+*/
+func (u *User) SetPrivacy(db *sql.DB, privacy Privacy) error {
+	if err := privacy.IsValid(); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO users(userID, privacy) VALUES(?, ?) ON CONFLICT(userID) DO UPDATE SET privacy = excluded.privacy"
+	if _, err := db.Exec(query, u.UserID, privacy); err != nil {
+		return err
+	}
+
+	u.Privacy = privacy
+	slog.Debug("privacy.SetPrivacy", "user", u.UserID, "privacy", privacy)
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// Set up a guild "g1" with members of every privacy level, and a guild "g2" shared only by "1" and "private"
+func addTestMembers(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	members := []struct {
+		guildID string
+		userID  string
+		privacy Privacy
+	}{
+		{"g1", "1", PRIVACY_GUILD},
+		{"g1", "private", PRIVACY_PRIVATE},
+		{"g1", "guild", PRIVACY_GUILD},
+		{"g1", "link", PRIVACY_LINK},
+		{"g1", "default", ""},
+		{"g2", "1", PRIVACY_GUILD},
+		{"g2", "private", PRIVACY_PRIVATE},
+		{"g2", "other", PRIVACY_GUILD},
+	}
+
+	for _, m := range members {
+		if err := RecordMember(db, m.guildID, m.userID, m.userID); err != nil {
+			t.Fatal(err)
+		}
+		if m.privacy == "" {
+			continue
+		}
+		if err := (&User{UserID: m.userID}).SetPrivacy(db, m.privacy); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCanView(t *testing.T) {
+	db := newTestDB(t)
+	addTestMembers(t, db)
+
+	tests := []struct {
+		name    string
+		viewer  *Viewer
+		ownerID string
+		allowed bool
+	}{
+		{"owner", &Viewer{UserID: "private"}, "private", true},
+		{"admin", &Viewer{Admin: true}, "private", true},
+		{"private in shared guild", &Viewer{UserID: "1", GuildID: "g1"}, "private", false},
+		{"guild in shared guild", &Viewer{UserID: "1", GuildID: "g1"}, "guild", true},
+		{"default in shared guild", &Viewer{UserID: "1", GuildID: "g1"}, "default", true},
+		{"guild from another guild", &Viewer{UserID: "1", GuildID: "g2"}, "guild", false},
+		{"guild from dms", &Viewer{UserID: "other"}, "1", true},
+		{"no shared guild", &Viewer{UserID: "other"}, "guild", false},
+		{"unknown owner", &Viewer{UserID: "1"}, "stranger", false},
+		{"link visitor to link", &Viewer{}, "link", true},
+		{"link visitor to guild", &Viewer{}, "guild", false},
+		{"link visitor to private", &Viewer{}, "private", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CanView(db, tt.viewer, tt.ownerID)
+
+			var private *PrivateWatchlistError
+			switch {
+			case tt.allowed && err != nil:
+				t.Errorf("CanView(%+v, %s) = %v, want nil", tt.viewer, tt.ownerID, err)
+			case !tt.allowed && !errors.As(err, &private):
+				t.Errorf("CanView(%+v, %s) = %v, want PrivateWatchlistError", tt.viewer, tt.ownerID, err)
+			}
+		})
+	}
+}
+
+func TestGuildUsersQuery(t *testing.T) {
+	db := newTestDB(t)
+	addTestMembers(t, db)
+
+	tests := []struct {
+		audience Privacy
+		guildID  string
+		want     []string
+	}{
+		{PRIVACY_GUILD, "g1", []string{"1", "default", "guild", "link"}},
+		{PRIVACY_LINK, "g1", []string{"link"}},
+		{PRIVACY_GUILD, "g2", []string{"1", "other"}},
+		{PRIVACY_GUILD, "g3", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.audience)+"/"+tt.guildID, func(t *testing.T) {
+			rows, err := db.Query(guildUsersQuery(tt.audience)+" ORDER BY m.userID", tt.guildID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var userID string
+				if err := rows.Scan(&userID); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, userID)
+			}
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("guildUsersQuery(%s) in %s = %v, want %v", tt.audience, tt.guildID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Number of entries shown in a recap's top rated list
+const RECAP_TOP_RATED = 5
+
+// Recap summarizes everything completed in one year
+type Recap struct {
+	*Summary
+	Year         int        `json:"year"`
+	Completions  []*Entry   `json:"completions"` // oldest completion first
+	TopRated     []*Entry   `json:"top_rated"`
+	TopCategory  Category   `json:"top_category"` // category with the most completions
+	PerMonth     [12]int    `json:"per_month"`    // completions per month, January first
+	BusiestMonth time.Month `json:"busiest_month"`
+
+	loc *time.Location
+}
+
+/*
+Summarize the entries completed in a year
+
+Entries completed before completion dates were recorded have no date and are left out.
+
+Params:
+
+	entries:	entries to look through (ex. a user's or a guild's watchlist, including watched items)
+	year:		year to recap
+	loc:		timezone the year and its months are counted in
+
+Returns:
+
+	*Recap:	ptr to the recap
+*/
+func ComputeRecap(entries []*Entry, year int, loc *time.Location) *Recap {
+	recap := &Recap{Year: year, BusiestMonth: time.January, loc: loc}
+
+	for _, e := range entries {
+		if !e.Done || e.DoneDate == nil || e.DoneDate.In(loc).Year() != year {
+			continue
+		}
+		recap.Completions = append(recap.Completions, e)
+		recap.PerMonth[e.DoneDate.In(loc).Month()-1]++
+	}
+
+	sort.SliceStable(recap.Completions, func(i, j int) bool {
+		return recap.Completions[i].DoneDate.Before(*recap.Completions[j].DoneDate)
+	})
+	recap.Summary = Summarize(recap.Completions)
+
+	for month, n := range recap.PerMonth {
+		if n > recap.PerMonth[recap.BusiestMonth-1] {
+			recap.BusiestMonth = time.Month(month + 1)
+		}
+	}
+	for _, category := range []Category{Movie, Show, Anime} {
+		if recap.ByCategory[category] > recap.ByCategory[recap.TopCategory] {
+			recap.TopCategory = category
+		}
+	}
+
+	// Highest rated first, earliest completion breaks ties
+	for _, e := range recap.Completions {
+		if e.Rating > 0 {
+			recap.TopRated = append(recap.TopRated, e)
+		}
+	}
+	sort.SliceStable(recap.TopRated, func(i, j int) bool {
+		return recap.TopRated[i].Rating > recap.TopRated[j].Rating
+	})
+	if len(recap.TopRated) > RECAP_TOP_RATED {
+		recap.TopRated = recap.TopRated[:RECAP_TOP_RATED]
+	}
+
+	return recap
+}
+
+// First entry completed in the year, nil if there are none
+func (r *Recap) First() *Entry {
+	if len(r.Completions) == 0 {
+		return nil
+	}
+	return r.Completions[0]
+}
+
+// Last entry completed in the year, nil if there are none
+func (r *Recap) Last() *Entry {
+	if len(r.Completions) == 0 {
+		return nil
+	}
+	return r.Completions[len(r.Completions)-1]
+}
+
+// Describe a completion, ex. "Dune (movie) on Mar 3"
+func (r *Recap) describe(e *Entry) string {
+	if e == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s) on %s", e.DisplayTitle(), e.Category, e.DoneDate.In(r.loc).Format("Jan 2"))
+}
+
+/*
+Render the recap as an embedded message
+
+Params:
+
+	title:	title of the embed
+
+Returns:
+
+	*discordgo.MessageEmbed:	ptr to the embed
+*/
+func (r *Recap) Embed(title string) *discordgo.MessageEmbed {
+	overview := []string{
+		fmt.Sprintf("completed  %4d", r.Total),
+		fmt.Sprintf("rated      %4d", r.Rated),
+	}
+	if r.Rated > 0 {
+		overview = append(overview, fmt.Sprintf("avg rating %4.1f", r.AverageRating))
+	}
+	for _, category := range []Category{Movie, Show, Anime} {
+		if n := r.ByCategory[category]; n > 0 {
+			overview = append(overview, fmt.Sprintf("%-10s %4d", category, n))
+		}
+	}
+
+	var busiest int
+	for _, n := range r.PerMonth {
+		busiest = max(busiest, n)
+	}
+	var months []string
+	for i, n := range r.PerMonth {
+		months = append(months, fmt.Sprintf("%s %-10s %d", time.Month(i + 1).String()[:3], textBar(n, busiest, 10), n))
+	}
+
+	var top []string
+	for i, e := range r.TopRated {
+		top = append(top, fmt.Sprintf("%d. %s (%s) - %d", i+1, e.DisplayTitle(), e.Category, e.Rating))
+	}
+	topRated := "nothing rated"
+	if len(top) > 0 {
+		topRated = "```\n" + strings.Join(top, "\n") + "\n```"
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: title,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Overview", Value: "```\n" + strings.Join(overview, "\n") + "\n```"},
+			{Name: "Top rated", Value: topRated},
+			{Name: "Most watched category", Value: string(r.TopCategory), Inline: true},
+			{Name: "Busiest month", Value: fmt.Sprintf("%s (%d)", r.BusiestMonth, r.PerMonth[r.BusiestMonth-1]), Inline: true},
+			{Name: "First completion", Value: r.describe(r.First())},
+			{Name: "Last completion", Value: r.describe(r.Last())},
+			{Name: "Per month", Value: "```\n" + strings.Join(months, "\n") + "\n```"},
+		},
+	}
+}
+
+/*
+Render the recap as a Markdown report listing every completion
+
+Params:
+
+	title:		title of the report
+	usernames:	userID -> username, adds a member column when not nil (ex. for guild recaps)
+
+Returns:
+
+	string:	the report
+*/
+func (r *Recap) Markdown(title string, usernames map[string]string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Completed: %d\n", r.Total)
+	if r.Rated > 0 {
+		fmt.Fprintf(&b, "- Average rating: %.1f from %d ratings\n", r.AverageRating, r.Rated)
+	}
+	fmt.Fprintf(&b, "- Most watched category: %s (%d)\n", r.TopCategory, r.ByCategory[r.TopCategory])
+	fmt.Fprintf(&b, "- Busiest month: %s (%d)\n", r.BusiestMonth, r.PerMonth[r.BusiestMonth-1])
+	fmt.Fprintf(&b, "- First completion: %s\n", r.describe(r.First()))
+	fmt.Fprintf(&b, "- Last completion: %s\n", r.describe(r.Last()))
+
+	if len(r.TopRated) > 0 {
+		b.WriteString("\n## Top rated\n\n")
+		for i, e := range r.TopRated {
+			fmt.Fprintf(&b, "%d. %s (%s) - %d\n", i+1, e.DisplayTitle(), e.Category, e.Rating)
+		}
+	}
+
+	b.WriteString("\n## Completions\n\n")
+	if usernames != nil {
+		b.WriteString("| Date | Title | Category | Rating | Member |\n| ---- | ----- | -------- | ------ | ------ |\n")
+	} else {
+		b.WriteString("| Date | Title | Category | Rating |\n| ---- | ----- | -------- | ------ |\n")
+	}
+	for _, e := range r.Completions {
+		rating := "-"
+		if e.Rating > 0 {
+			rating = fmt.Sprint(e.Rating)
+		}
+
+		// Pipes would break the table
+		title := strings.ReplaceAll(e.DisplayTitle(), "|", "\\|")
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |", e.DoneDate.In(r.loc).Format("2006-01-02"), title, e.Category, rating)
+		if usernames != nil {
+			fmt.Fprintf(&b, " %s |", strings.ReplaceAll(usernames[e.UserID], "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
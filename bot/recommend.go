@@ -0,0 +1,233 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	// Default number of recommendations
+	DEFAULT_RECOMMEND_COUNT = 5
+
+	// Similarities between titles few members rated are shrunk towards 0 by n / (n + RECOMMEND_SHRINKAGE)
+	RECOMMEND_SHRINKAGE = 2
+)
+
+// Recommendation is a title the user hasn't added, with why it was picked
+type Recommendation struct {
+	Title    string   `json:"title"`
+	Category Category `json:"category"`
+	Year     int      `json:"year,omitempty"`
+	Score    float64  `json:"score"`   // predicted rating
+	Average  float64  `json:"average"` // average rating from other members
+	Votes    int      `json:"votes"`   // number of members that rated it
+
+	// Title of the user's rated entry that contributed the most to the score, empty for popular picks
+	Because       string `json:"because,omitempty"`
+	BecauseRating int    `json:"because_rating,omitempty"`
+}
+
+// Explanation shown with a recommendation
+func (r *Recommendation) Reason() string {
+	if r.Because != "" {
+		return fmt.Sprintf("because you rated %s %d", r.Because, r.BecauseRating)
+	}
+	if r.Votes == 1 {
+		return fmt.Sprintf("rated %.0f by another member", r.Average)
+	}
+	return fmt.Sprintf("rated %.1f on average by %d members", r.Average, r.Votes)
+}
+
+// A rated title, keyed by compareKey
+type ratedItem struct {
+	title    string
+	category Category
+	year     int
+	ratings  map[string]float64 // userID -> rating
+}
+
+/*
+Recommend titles other members of a guild rated highly that the user hasn't added
+
+Uses item-item collaborative filtering: titles are similar when the members that rated
+both rated them alike (relative to each member's average rating), and a title's score is
+the user's average plus the similarity-weighted ratings of the titles they rated.
+Users without ratings in common with anyone get the guild's most popular titles instead
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user to recommend titles to
+	guildID:	guild whose members' ratings are used (members with private watchlists are left out)
+	category:	only recommend this category, empty for any
+	count:		maximum number of recommendations
+
+Returns:
+
+	[]*Recommendation:	recommendations, best first
+	error:				error object
+*/
+func Recommend(db *sql.DB, userID string, guildID string, category Category, count int) ([]*Recommendation, error) {
+	query := "SELECT userID, title, category, year, rating FROM entries WHERE rating > 0 AND " + NOT_DELETED + " AND (userID = ? OR userID IN (" + guildUsersQuery(PRIVACY_GUILD) + "))"
+	rows, err := db.Query(query, userID, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[string]*ratedItem)
+	users := make(map[string]map[string]float64) // userID -> item key -> rating
+	for rows.Next() {
+		var (
+			e      Entry
+			rating float64
+		)
+		if err := rows.Scan(&e.UserID, &e.Title, &e.Category, &e.Year, &rating); err != nil {
+			return nil, err
+		}
+
+		key := compareKey(&e)
+		item, ok := items[key]
+		if !ok {
+			item = &ratedItem{title: e.Title, category: e.Category, year: e.Year, ratings: make(map[string]float64)}
+			items[key] = item
+		}
+		item.ratings[e.UserID] = rating
+
+		if users[e.UserID] == nil {
+			users[e.UserID] = make(map[string]float64)
+		}
+		users[e.UserID][key] = rating
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Titles already on the user's list (rated or not) are never recommended
+	watchlist, err := fetchWatchlist(db, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]bool, len(watchlist.Entries))
+	for _, e := range watchlist.Entries {
+		added[compareKey(e)] = true
+	}
+
+	// Center every member's ratings on their own average, so harsh and generous raters compare
+	var guildTotal, guildVotes float64
+	means := make(map[string]float64, len(users))
+	for user, ratings := range users {
+		var total float64
+		for _, rating := range ratings {
+			total += rating
+		}
+		means[user] = total / float64(len(ratings))
+		guildTotal += total
+		guildVotes += float64(len(ratings))
+	}
+
+	var recommendations []*Recommendation
+	for key, candidate := range items {
+		if added[key] || (category != "" && candidate.category != category) {
+			continue
+		}
+
+		r := &Recommendation{Title: candidate.title, Category: candidate.category, Year: candidate.year, Votes: len(candidate.ratings)}
+		for _, rating := range candidate.ratings {
+			r.Average += rating / float64(r.Votes)
+		}
+
+		var weighted, weights, best float64
+		for rated, rating := range users[userID] {
+			sim := itemSimilarity(items[rated], candidate, means)
+			if sim <= 0 {
+				continue
+			}
+
+			weighted += sim * (rating - means[userID])
+			weights += sim
+
+			if contribution := sim * rating; contribution > best {
+				best = contribution
+				r.Because, r.BecauseRating = displayTitle(items[rated].title, items[rated].year), int(rating)
+			}
+		}
+
+		switch {
+		case weights > 0 && weighted > 0:
+			r.Score = means[userID] + weighted/weights
+		case weights == 0 && r.Average >= guildTotal/guildVotes:
+			// Nothing in common, fall back to titles rated above the guild's average
+			r.Score = r.Average
+		default:
+			// Predicted to be below the user's average (or unpopular)
+			continue
+		}
+		recommendations = append(recommendations, r)
+	}
+
+	// Personalized picks first, then by score and number of votes
+	sort.Slice(recommendations, func(i, j int) bool {
+		a, b := recommendations[i], recommendations[j]
+		if (a.Because != "") != (b.Because != "") {
+			return a.Because != ""
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+
+	if len(recommendations) > count {
+		recommendations = recommendations[:count]
+	}
+	return recommendations, nil
+}
+
+// Adjusted cosine similarity of two titles over the members that rated both, shrunk when few did
+func itemSimilarity(a *ratedItem, b *ratedItem, means map[string]float64) float64 {
+	var dot, normA, normB float64
+	var common int
+	for user, ratingA := range a.ratings {
+		ratingB, ok := b.ratings[user]
+		if !ok {
+			continue
+		}
+		x, y := ratingA-means[user], ratingB-means[user]
+		dot += x * y
+		normA += x * x
+		normB += y * y
+		common++
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB) * float64(common) / float64(common+RECOMMEND_SHRINKAGE)
+}
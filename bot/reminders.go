@@ -0,0 +1,292 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Reminder represents a scheduled message about an entry (or a random pick)
+type Reminder struct {
+	ReminderID int64     `json:"reminder_id"`
+	UserID     string    `json:"user_id"`
+	ChannelID  string    `json:"channel_id"`
+	DM         bool      `json:"dm"`
+	Title      string    `json:"title"` // empty for random pick reminders
+	Category   Category  `json:"category"`
+	Year       int       `json:"year,omitempty"`
+	Due        time.Time `json:"due"`
+	Repeat     Repeat    `json:"repeat"`
+	failures   int       // failed sends since the reminder came due
+}
+
+// Repeat represents how often a reminder fires
+type Repeat string
+
+const (
+	// Enumerations for repeating reminders
+	REPEAT_NONE    Repeat = ""
+	REPEAT_DAILY   Repeat = "daily"
+	REPEAT_WEEKLY  Repeat = "weekly"
+	REPEAT_MONTHLY Repeat = "monthly"
+)
+
+const REMINDER_COLUMNS = "reminderID, userID, channelID, dm, title, category, year, due, repeat, failures"
+
+// Failed sends before a due reminder is given up on and deleted or rescheduled
+const REMINDER_MAX_FAILURES = 10
+
+// Saves a reminder to the database and sets its ID
+func (r *Reminder) Add(db *sql.DB) error {
+	query := "INSERT INTO reminders(userID, channelID, dm, title, category, year, due, repeat) VALUES(?, ?, ?, ?, ?, ?, ?, ?)"
+	result, err := db.Exec(query, r.UserID, r.ChannelID, r.DM, r.Title, r.Category, r.Year, r.Due.UTC(), r.Repeat)
+	if err != nil {
+		return err
+	}
+
+	r.ReminderID, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("reminders.Add", "reminder", r)
+	return nil
+}
+
+/*
+Fetch a user's reminders, soonest first
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	userID:	user ID to fetch reminders for
+
+Returns:
+
+	[]*Reminder:	the user's reminders
+	error:			error object
+*/
+func FetchReminders(db *sql.DB, userID string) ([]*Reminder, error) {
+	query := "SELECT " + REMINDER_COLUMNS + " FROM reminders WHERE userID = ? ORDER BY due"
+	return queryReminders(db, query, userID)
+}
+
+/*
+Cancel one of a user's reminders
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user ID that owns the reminder
+	reminderID:	ID of the reminder to cancel
+
+Returns:
+
+	error:	ReminderNotFoundError if the user has no reminder with that ID
+*/
+func CancelReminder(db *sql.DB, userID string, reminderID int64) error {
+	result, err := db.Exec("DELETE FROM reminders WHERE reminderID = ? AND userID = ?", reminderID, userID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &ReminderNotFoundError{userID, reminderID}
+	}
+
+	slog.Debug("reminders.CancelReminder", "user", userID, "reminder", reminderID)
+	return nil
+}
+
+/*
+Send every reminder that is due, then reschedule or delete it
+
+Runs as a scheduler job, reminders that came due while the bot was offline are sent on the next run.
+Reminders that fail to send stay due and are retried on the next run, up to REMINDER_MAX_FAILURES times.
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	s:		ptr to discord session
+	now:	current time
+*/
+func sendDueReminders(db *sql.DB, s *discordgo.Session, now time.Time) error {
+	// Reminders for trashed entries wait until the entry is restored, or go with it when it's purged
+	query := "SELECT " + REMINDER_COLUMNS + " FROM reminders r WHERE due <= ? AND NOT EXISTS(SELECT 1 FROM entries e " +
+		"WHERE e.userID = r.userID AND e.title = r.title AND e.category = r.category AND e.year = r.year AND e.deleted IS NOT NULL)"
+	due, err := queryReminders(db, query, now.UTC())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range due {
+		if err := r.send(db, s); err != nil {
+			// Keep going, one unreachable user shouldn't block everyone else's reminders
+			r.failures++
+			slog.Error("reminders.sendDueReminders", "reminder", r.ReminderID, "failures", r.failures, "msg", err)
+
+			// Leave it due for the next run, until it's failed too many times to be worth retrying
+			if r.failures < REMINDER_MAX_FAILURES {
+				if _, err := db.Exec("UPDATE reminders SET failures = ? WHERE reminderID = ?", r.failures, r.ReminderID); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if r.Repeat == REPEAT_NONE {
+			_, err = db.Exec("DELETE FROM reminders WHERE reminderID = ?", r.ReminderID)
+		} else {
+			// Repeat in the user's timezone so "weekly at 20:00" stays at 20:00 across DST changes,
+			// and skip any occurrences missed while offline
+			next := r.Due.In(userLocation(db, r.UserID))
+			for !next.After(now) {
+				next = r.Repeat.next(next)
+			}
+			_, err = db.Exec("UPDATE reminders SET due = ?, failures = 0 WHERE reminderID = ?", next.UTC(), r.ReminderID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver a reminder to its channel or the user's DMs
+func (r *Reminder) send(db *sql.DB, s *discordgo.Session) error {
+	channelID := r.ChannelID
+	if r.DM {
+		channel, err := s.UserChannelCreate(r.UserID)
+		if err != nil {
+			return err
+		}
+		channelID = channel.ID
+	}
+
+	// Random pick reminders pick at send time, not when the reminder was set
+	title := displayTitle(r.Title, r.Year)
+	if r.Title == "" {
+		picks, err := Pick(db, r.UserID, &PickOptions{Weighting: WEIGHT_NONE, Count: 1})
+
+		// Retrying won't give the user something to pick, so the reminder goes out without a pick
+		var (
+			empty     *EmptyWatchlistError
+			noMatches *NoMatchingEntriesError
+		)
+		switch {
+		case errors.As(err, &empty), errors.As(err, &noMatches):
+			title = ""
+		case err != nil:
+			return err
+		default:
+			title = picks[0].DisplayTitle()
+		}
+	}
+
+	message := fmt.Sprintf("<@%s> reminder to watch ```%s```", r.UserID, title)
+	if title == "" {
+		message = fmt.Sprintf("<@%s> reminder to watch something, but there's nothing unwatched on your watchlist to pick. Add something with ./watchlist %s", r.UserID, ADD_COMMAND)
+	}
+	if _, err := s.ChannelMessageSend(channelID, message); err != nil {
+		return err
+	}
+
+	slog.Info("reminders.send", "reminder", r.ReminderID, "user", r.UserID, "title", title)
+	return nil
+}
+
+// Run a reminder query and scan the results
+func queryReminders(db *sql.DB, query string, args ...any) ([]*Reminder, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []*Reminder
+	for rows.Next() {
+		var r Reminder
+		err := rows.Scan(&r.ReminderID, &r.UserID, &r.ChannelID, &r.DM, &r.Title, &r.Category, &r.Year, &r.Due, &r.Repeat, &r.failures)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, &r)
+	}
+
+	return reminders, rows.Err()
+}
+
+// Next occurrence of a repeating reminder after t
+func (r Repeat) next(t time.Time) time.Time {
+	switch r {
+	case REPEAT_DAILY:
+		return t.AddDate(0, 0, 1)
+	case REPEAT_WEEKLY:
+		return t.AddDate(0, 0, 7)
+	case REPEAT_MONTHLY:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t
+	}
+}
+
+// enum validation
+func (r *Repeat) IsValid() error {
+	switch *r {
+	case REPEAT_NONE, REPEAT_DAILY, REPEAT_WEEKLY, REPEAT_MONTHLY:
+		return nil
+	default:
+		return &InvalidRepeatError{r}
+	}
+}
+
+// Stringer for reminder struct
+func (r *Reminder) String() string {
+	return r.Format(time.UTC)
+}
+
+// Describe a reminder with its due date in the given timezone
+func (r *Reminder) Format(loc *time.Location) string {
+	what := displayTitle(r.Title, r.Year)
+	if r.Title == "" {
+		what = "a random pick"
+	}
+
+	where := fmt.Sprintf("in <#%s>", r.ChannelID)
+	if r.DM {
+		where = "in DMs"
+	}
+
+	when := formatTime(r.Due, loc)
+	if r.Repeat != REPEAT_NONE {
+		when += fmt.Sprintf(", then %s", r.Repeat)
+	}
+
+	return fmt.Sprintf("#%d %s (%s, %s)", r.ReminderID, what, when, where)
+}
@@ -0,0 +1,218 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Fake discord API that accepts messages, except in channels that are down
+type fakeDiscord struct {
+	mu   sync.Mutex
+	down map[string]bool   // channel IDs that fail with a 403
+	sent map[string]string // last message sent to each channel
+}
+
+func (f *fakeDiscord) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// POST /api/v9/channels/{channelID}/messages
+	parts := strings.Split(r.URL.Path, "/")
+	channelID := parts[len(parts)-2]
+
+	if f.down[channelID] {
+		return fakeResponse(r, http.StatusForbidden, `{"message": "Missing Access", "code": 50001}`), nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.sent[channelID] = string(body)
+	return fakeResponse(r, http.StatusOK, `{"id": "1", "channel_id": "`+channelID+`"}`), nil
+}
+
+func fakeResponse(r *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
+
+// Discord session that talks to a fake API instead of discord
+func newTestSession(t *testing.T, down ...string) (*discordgo.Session, *fakeDiscord) {
+	t.Helper()
+
+	fake := &fakeDiscord{down: make(map[string]bool), sent: make(map[string]string)}
+	for _, channelID := range down {
+		fake.down[channelID] = true
+	}
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Client = &http.Client{Transport: fake}
+	s.MaxRestRetries = 0
+	return s, fake
+}
+
+// A user's reminders by ID, failing the test on errors
+func testReminders(t *testing.T, db *sql.DB, userID string) map[int64]*Reminder {
+	t.Helper()
+
+	reminders, err := FetchReminders(db, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[int64]*Reminder)
+	for _, r := range reminders {
+		byID[r.ReminderID] = r
+	}
+	return byID
+}
+
+func TestSendDueReminders(t *testing.T) {
+	db := newTestDB(t)
+	s, fake := newTestSession(t, "down")
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	sent := &Reminder{UserID: "1", ChannelID: "up", Title: "Dune", Category: Movie, Due: now.Add(-time.Minute)}
+	failing := &Reminder{UserID: "1", ChannelID: "down", Title: "Alien", Category: Movie, Due: now.Add(-time.Minute)}
+	weekly := &Reminder{UserID: "1", ChannelID: "down", Title: "Heat", Category: Movie, Due: now.Add(-time.Minute), Repeat: REPEAT_WEEKLY}
+	later := &Reminder{UserID: "1", ChannelID: "up", Title: "Jaws", Category: Movie, Due: now.Add(time.Hour)}
+	for _, r := range []*Reminder{sent, failing, weekly, later} {
+		if err := r.Add(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A failing channel doesn't hold up the others, and its reminders stay due
+	if err := sendDueReminders(db, s, now); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.sent["up"], "Dune") {
+		t.Errorf("sent %q to the channel, want the Dune reminder", fake.sent["up"])
+	}
+
+	reminders := testReminders(t, db, "1")
+	if _, ok := reminders[sent.ReminderID]; ok {
+		t.Error("sent one-off reminder wasn't deleted")
+	}
+	if _, ok := reminders[later.ReminderID]; !ok {
+		t.Error("reminder that isn't due yet was deleted")
+	}
+	for _, r := range []*Reminder{failing, weekly} {
+		got, ok := reminders[r.ReminderID]
+		if !ok || got.failures != 1 || !got.Due.Equal(r.Due) {
+			t.Errorf("failed %s reminder = %+v, want it kept due with 1 failure", r.Title, got)
+		}
+	}
+
+	// Retried on every run until it's failed REMINDER_MAX_FAILURES times
+	for run := 2; run <= REMINDER_MAX_FAILURES; run++ {
+		if err := sendDueReminders(db, s, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reminders = testReminders(t, db, "1")
+	if _, ok := reminders[failing.ReminderID]; ok {
+		t.Errorf("one-off reminder is kept after %d failures, want it given up on", REMINDER_MAX_FAILURES)
+	}
+	got, ok := reminders[weekly.ReminderID]
+	if !ok || got.failures != 0 || !got.Due.Equal(weekly.Due.AddDate(0, 0, 7)) {
+		t.Errorf("weekly reminder = %+v, want it moved to next week with no failures", got)
+	}
+}
+
+func TestSendDueRemindersRecovers(t *testing.T) {
+	db := newTestDB(t)
+	s, fake := newTestSession(t, "flaky")
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	r := &Reminder{UserID: "1", ChannelID: "flaky", Title: "Dune", Category: Movie, Due: now.Add(-time.Minute)}
+	if err := r.Add(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := sendDueReminders(db, s, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sent on the next run once the channel is back
+	fake.down["flaky"] = false
+	if err := sendDueReminders(db, s, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.sent["flaky"], "Dune") {
+		t.Errorf("sent %q after the channel came back, want the Dune reminder", fake.sent["flaky"])
+	}
+	if reminders := testReminders(t, db, "1"); len(reminders) != 0 {
+		t.Errorf("got %d reminders left, want the sent reminder deleted", len(reminders))
+	}
+}
+
+func TestSendDueRemindersNothingToPick(t *testing.T) {
+	db := newTestDB(t)
+	s, fake := newTestSession(t)
+	now := time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	// User 2 has watched everything, user 1 has nothing at all
+	addTestEntries(t, db, &Entry{UserID: "2", Title: "Dune", Category: Movie})
+	if _, err := DoneEntry(db, TEST_ACTOR, "2", "Dune", Movie, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := &Reminder{UserID: "1", ChannelID: "empty", Due: now.Add(-time.Minute)}
+	watched := &Reminder{UserID: "2", ChannelID: "watched", Due: now.Add(-time.Minute), Repeat: REPEAT_WEEKLY}
+	for _, r := range []*Reminder{empty, watched} {
+		if err := r.Add(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sendDueReminders(db, s, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delivered without a pick instead of failing and being retried
+	for _, channelID := range []string{"empty", "watched"} {
+		if !strings.Contains(fake.sent[channelID], "nothing unwatched") {
+			t.Errorf("sent %q to %s, want a reminder saying there's nothing to pick", fake.sent[channelID], channelID)
+		}
+	}
+	if reminders := testReminders(t, db, "1"); len(reminders) != 0 {
+		t.Errorf("got %d reminders for user 1, want the one-off reminder deleted", len(reminders))
+	}
+	got, ok := testReminders(t, db, "2")[watched.ReminderID]
+	if !ok || got.failures != 0 || !got.Due.Equal(watched.Due.AddDate(0, 0, 7)) {
+		t.Errorf("weekly reminder = %+v, want it moved to next week with no failures", got)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/mattn/go-sqlite3"
+)
+
+// How long error replies stay in a server channel before they're deleted,
+// messages can't be ephemeral like interaction replies so they clean up after themselves instead
+var ERROR_REPLY_LIFETIME = time.Minute
+
+/*
+Describe an error the way users should see it
+
+Typed errors from errors.go are mistakes the user can fix and are shown as they are or reworded,
+anything else is a storage or discord failure and gets a generic message so internals don't leak.
+
+Params:
+
+	err:	error returned while handling a command
+
+Returns:
+
+	string:	friendly description of the error
+	bool:	true if the command's usage would help the user fix it
+	bool:	true if the error is the user's to fix, false if something went wrong on our end
+*/
+func describeError(err error) (message string, usage bool, expected bool) {
+	var (
+		notEnough     *NotEnoughArgumentsError
+		badNumber     *InvalidNumberError
+		badPosition   *InvalidPositionError
+		badTitle      *InvalidTitleError
+		badCategory   *InvalidCategoryError
+		badTimestamp  *InvalidTimestampError
+		badSort       *InvalidSortByError
+		badWeighting  *InvalidWeightingError
+		badPick       *InvalidPickOptionError
+		badDate       *InvalidDateError
+		badRepeat     *InvalidRepeatError
+		badRSVP       *InvalidRSVPError
+		badTimezone   *InvalidTimezoneError
+		badPrivacy    *InvalidPrivacyError
+		badYear       *InvalidYearError
+		badRating     *InvalidRatingError
+		badLink       *InvalidLinkError
+		badLinkKind   *InvalidLinkKindError
+		badService    *InvalidServiceError
+		badRegion     *InvalidRegionError
+		badOffer      *InvalidOfferError
+		ambiguous     *AmbiguousEntryError
+		notFound      *EntryNotFoundError
+		empty         *EmptyWatchlistError
+		noMatches     *NoMatchingEntriesError
+		noReminder    *ReminderNotFoundError
+		nothingToUndo *NothingToUndoError
+		notInTrash    *NotInTrashError
+		private       *PrivateWatchlistError
+		badUser       *InvalidUserIDError
+		noParty       *PartyNotFoundError
+		partyClosed   *PartyClosedError
+		notHost       *NotPartyHostError
+		badToken      *InvalidTokenError
+		noShare       *ShareNotFoundError
+		noMetadata    *MetadataNotFoundError
+		noLink        *LinkNotFoundError
+		notStreaming  *AvailabilityNotFoundError
+		noProvider    *NoAvailabilityProviderError
+		inTrash       *EntryInTrashError
+		notAdmin      *NotGuildAdminError
+		notInGuild    *NotInGuildError
+		cannotDM      *CannotDMError
+		noPendingAdd  *PendingAddNotFoundError
+		sqliteErr     sqlite3.Error
+	)
+
+	switch {
+	case errors.As(err, &notEnough):
+		return "That's missing something", true, true
+	case errors.As(err, &badNumber), errors.As(err, &badTitle), errors.As(err, &badCategory), errors.As(err, &badTimestamp),
+		errors.As(err, &badSort), errors.As(err, &badWeighting), errors.As(err, &badPick), errors.As(err, &badDate),
+		errors.As(err, &badRepeat), errors.As(err, &badRSVP), errors.As(err, &badTimezone), errors.As(err, &badPrivacy),
+		errors.As(err, &badYear), errors.As(err, &badLink), errors.As(err, &badLinkKind), errors.As(err, &badService),
+		errors.As(err, &badRegion), errors.As(err, &badOffer), errors.As(err, &badRating), errors.As(err, &badPosition),
+		errors.As(err, &ambiguous):
+		return err.Error(), true, true
+
+	// Reworded, the originals are written for logs and name users by ID
+	case errors.As(err, &notFound):
+		return fmt.Sprintf("%s isn't in your watchlist, check the spelling or see ./watchlist %s", notFound.title, VIEW_COMMAND), false, true
+	case errors.As(err, &empty):
+		return fmt.Sprintf("Your watchlist is empty, add something with ./watchlist %s", ADD_COMMAND), false, true
+	case errors.As(err, &noMatches):
+		return "Nothing in your watchlist matches those options", false, true
+	case errors.As(err, &noReminder):
+		return fmt.Sprintf("You have no reminder #%d, see ./watchlist %s list", noReminder.reminderID, REMIND_COMMAND), false, true
+	case errors.As(err, &nothingToUndo):
+		return fmt.Sprintf("Nothing to undo from the last %.0f hours", UNDO_WINDOW.Hours()), false, true
+	case errors.As(err, &notInTrash):
+		return fmt.Sprintf("%s isn't in your trash, see ./watchlist %s", notInTrash.title, TRASH_COMMAND), false, true
+	case errors.As(err, &private):
+		return "That watchlist isn't visible to you", false, true
+	case errors.As(err, &badUser):
+		return "Couldn't tell whose watchlist that's for", false, true
+	case errors.As(err, &notHost):
+		return fmt.Sprintf("Only the host of watch party #%d can do that", notHost.partyID), false, true
+	case errors.As(err, &notAdmin):
+		return "Only server admins can view the audit log", false, true
+
+	case errors.As(err, &noParty), errors.As(err, &partyClosed), errors.As(err, &badToken), errors.As(err, &noShare),
+		errors.As(err, &noMetadata), errors.As(err, &noLink), errors.As(err, &notStreaming), errors.As(err, &noProvider),
+		errors.As(err, &inTrash), errors.As(err, &notInGuild), errors.As(err, &cannotDM), errors.As(err, &noPendingAdd):
+		return err.Error(), false, true
+
+	case errors.As(err, &sqliteErr) && (sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique):
+		// Composite primary key, the entry already exists
+		return "That's already in your watchlist", false, true
+	default:
+		return "Something went wrong on our end, please try again later", false, false
+	}
+}
+
+/*
+Reply to a command that failed, with the command's usage if it would help
+
+Params:
+
+	s:			ptr to discord session
+	m:			ptr to the discord message that failed
+	command:	command that failed, ex. ADD_COMMAND
+	err:		error the command failed with
+*/
+func replyError(s *discordgo.Session, m *discordgo.MessageCreate, command string, err error) {
+	message, usage, expected := describeError(err)
+	if expected {
+		slog.Warn("replies.replyError", "command", command, "user", m.Author.Username, "msg", err)
+	} else {
+		slog.Error("replies.replyError", "command", command, "user", m.Author.Username, "msg", err)
+	}
+
+	reply := fmt.Sprintf("```%s```", message)
+	if help, ok := helpMessages[command]; ok && usage {
+		reply += "\n" + help
+	}
+
+	sent, err := s.ChannelMessageSendReply(m.ChannelID, reply, m.Reference())
+	if err != nil {
+		slog.Error("replies.replyError", "msg", err)
+		return
+	}
+
+	// DMs are already private
+	if m.GuildID != "" {
+		time.AfterFunc(ERROR_REPLY_LIFETIME, func() {
+			if err := s.ChannelMessageDelete(sent.ChannelID, sent.ID); err != nil {
+				slog.Error("replies.replyError", "msg", err)
+			}
+		})
+	}
+}
+
+// Reply to a button press that failed with a message only the user can see
+func respondError(s *discordgo.Session, i *discordgo.InteractionCreate, err error) {
+	message, _, expected := describeError(err)
+	if expected {
+		slog.Warn("replies.respondError", "custom_id", i.MessageComponentData().CustomID, "user", interactionUser(i).Username, "msg", err)
+	} else {
+		slog.Error("replies.respondError", "custom_id", i.MessageComponentData().CustomID, "user", interactionUser(i).Username, "msg", err)
+	}
+
+	respondEphemeral(s, i, fmt.Sprintf("```%s```", message))
+}
@@ -0,0 +1,62 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDescribeError(t *testing.T) {
+	const SNOWFLAKE = "123456789012345678"
+
+	tests := []struct {
+		name     string
+		err      error
+		want     string
+		usage    bool
+		expected bool
+	}{
+		{"not enough arguments", &NotEnoughArgumentsError{"title"}, "That's missing something", true, true},
+		{"invalid rating", &InvalidRatingError{11}, (&InvalidRatingError{11}).Error(), true, true},
+		{"position past the end", &InvalidPositionError{2021, 3}, "Invalid position: 2021 (your watchlist only goes up to #4, a release year goes before the category)", true, true},
+		{"wrapped not found", fmt.Errorf("done: %w", &EntryNotFoundError{SNOWFLAKE, "Dune", Movie}), "Dune isn't in your watchlist, check the spelling or see ./watchlist view", false, true},
+		{"nothing to undo", &NothingToUndoError{SNOWFLAKE}, "Nothing to undo from the last 24 hours", false, true},
+		{"invalid user", &InvalidUserIDError{SNOWFLAKE}, "Couldn't tell whose watchlist that's for", false, true},
+		{"not the host", &NotPartyHostError{SNOWFLAKE, 7}, "Only the host of watch party #7 can do that", false, true},
+		{"not an admin", &NotGuildAdminError{SNOWFLAKE}, "Only server admins can view the audit log", false, true},
+		{"unexpected", errors.New("database is locked"), "Something went wrong on our end, please try again later", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, usage, expected := describeError(tt.err)
+			if message != tt.want || usage != tt.usage || expected != tt.expected {
+				t.Errorf("describeError(%v) = %q, %v, %v, want %q, %v, %v", tt.err, message, usage, expected, tt.want, tt.usage, tt.expected)
+			}
+
+			// Users are named by mention or not at all, never by raw ID
+			if strings.Contains(message, SNOWFLAKE) {
+				t.Errorf("describeError(%v) = %q shows a user ID", tt.err, message)
+			}
+		})
+	}
+}
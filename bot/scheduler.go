@@ -0,0 +1,74 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// How often the scheduler checks for due jobs
+const SCHEDULER_INTERVAL = 30 * time.Second
+
+// A job is run on every scheduler tick with the current time
+type job func(db *sql.DB, s *discordgo.Session, now time.Time) error
+
+// Jobs run by the scheduler on every tick, by name
+var jobs = map[string]job{
+	"reminders": sendDueReminders,
+	"parties":   runParties,
+	"trash":     purgeTrash,
+}
+
+/*
+Start running scheduled jobs in the background
+
+Everything the scheduler works on is persisted in the database,
+so nothing is lost when the bot restarts
+
+Params:
+
+	db:	ptr to sqlite3 database connection
+	s:	ptr to discord session used to deliver messages
+*/
+func StartScheduler(db *sql.DB, s *discordgo.Session) {
+	go func() {
+		ticker := time.NewTicker(SCHEDULER_INTERVAL)
+		defer ticker.Stop()
+
+		// Run once immediately to catch up on anything that came due while offline
+		runJobs(db, s, time.Now())
+		for now := range ticker.C {
+			runJobs(db, s, now)
+		}
+	}()
+}
+
+// Run every job once, logging failures
+func runJobs(db *sql.DB, s *discordgo.Session, now time.Time) {
+	for name, run := range jobs {
+		if err := run(db, s, now); err != nil {
+			slog.Error("scheduler.runJobs", "job", name, "msg", err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"log/slog"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Base URL share links point at, set from the command line
+var PUBLIC_URL = "http://localhost:8080"
+
+// Share represents a read-only link to a user's watchlist, or a guild's when GuildID is set
+type Share struct {
+	ShareID string    `json:"share_id"`
+	UserID  string    `json:"user_id"`
+	GuildID string    `json:"guild_id"`
+	Created time.Time `json:"created"`
+}
+
+/*
+Get the user's share link for their watchlist (or a guild's), creating one if needed
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user the link belongs to
+	guildID:	guild to share, empty to share the user's own watchlist
+
+Returns:
+
+	*Share:	ptr to the share link
+	error:	error object
+*/
+func FetchOrCreateShare(db *sql.DB, userID string, guildID string) (*Share, error) {
+	share := &Share{UserID: userID, GuildID: guildID}
+
+	query := "SELECT shareID, created FROM shares WHERE userID = ? AND guildID = ?"
+	err := db.QueryRow(query, userID, guildID).Scan(&share.ShareID, &share.Created)
+	if err != sql.ErrNoRows {
+		return share, err
+	}
+
+	// 128 random bits, so links can't be guessed
+	secret := make([]byte, 16)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, err
+	}
+	share.ShareID = base64.RawURLEncoding.EncodeToString(secret)
+	share.Created = time.Now().UTC()
+
+	_, err = db.Exec("INSERT INTO shares(shareID, userID, guildID, created) VALUES(?, ?, ?, ?)", share.ShareID, userID, guildID, share.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("shares.FetchOrCreateShare", "user", userID, "guild", guildID)
+	return share, nil
+}
+
+/*
+Fetch a share link by its ID
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	shareID:	ID from the link
+
+Returns:
+
+	*Share:	ptr to the share link
+	error:	ShareNotFoundError if the link doesn't exist or was revoked
+*/
+func FetchShare(db *sql.DB, shareID string) (*Share, error) {
+	share := &Share{ShareID: shareID}
+	query := "SELECT userID, guildID, created FROM shares WHERE shareID = ?"
+	err := db.QueryRow(query, shareID).Scan(&share.UserID, &share.GuildID, &share.Created)
+	if err == sql.ErrNoRows {
+		return nil, &ShareNotFoundError{}
+	}
+	return share, err
+}
+
+/*
+Revoke the user's share link for their watchlist (or a guild's)
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	userID:		user the link belongs to
+	guildID:	guild that was shared, empty for the user's own watchlist
+
+Returns:
+
+	error:	ShareNotFoundError if there was no link to revoke
+*/
+func RevokeShare(db *sql.DB, userID string, guildID string) error {
+	result, err := db.Exec("DELETE FROM shares WHERE userID = ? AND guildID = ?", userID, guildID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &ShareNotFoundError{}
+	}
+
+	slog.Debug("shares.RevokeShare", "user", userID, "guild", guildID)
+	return nil
+}
+
+// Full link to the share page
+func (s *Share) URL() string {
+	return PUBLIC_URL + "/share/" + s.ShareID
+}
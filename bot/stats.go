@@ -0,0 +1,262 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Summary holds totals over a set of entries
+type Summary struct {
+	Total         int              `json:"total"`
+	Done          int              `json:"done"`
+	ByCategory    map[Category]int `json:"by_category"`
+	Rated         int              `json:"rated"`
+	AverageRating float64          `json:"average_rating"` // over rated entries only
+}
+
+// Compute totals over a set of entries
+func Summarize(entries []*Entry) *Summary {
+	summary := &Summary{ByCategory: make(map[Category]int)}
+
+	var ratingTotal int
+	for _, e := range entries {
+		summary.Total++
+		summary.ByCategory[e.Category]++
+
+		if e.Done {
+			summary.Done++
+		}
+		if e.Rating > 0 {
+			summary.Rated++
+			ratingTotal += e.Rating
+		}
+	}
+
+	if summary.Rated > 0 {
+		summary.AverageRating = float64(ratingTotal) / float64(summary.Rated)
+	}
+	return summary
+}
+
+// Fraction of entries that are done, between 0 and 1
+func (s *Summary) CompletionRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Done) / float64(s.Total)
+}
+
+// CategoryStats holds totals for one category
+type CategoryStats struct {
+	Total         int     `json:"total"`
+	Done          int     `json:"done"`
+	Rated         int     `json:"rated"`
+	AverageRating float64 `json:"average_rating"` // over rated entries only
+}
+
+// Stats holds a detailed breakdown of a user's watchlist
+type Stats struct {
+	*Summary
+	Categories      map[Category]*CategoryStats `json:"categories"`
+	RatingHistogram map[int]int                 `json:"rating_histogram"` // rating -> number of entries
+	AddedPerMonth   map[string]int              `json:"added_per_month"`  // "2006-01" -> entries added
+	DonePerMonth    map[string]int              `json:"done_per_month"`   // "2006-01" -> entries completed
+	LongestWaiting  *Entry                      `json:"longest_waiting"`  // oldest unwatched entry, nil if none
+	Watches         int                         `json:"watches"`          // viewings, counting rewatches
+	Rewatches       int                         `json:"rewatches"`        // viewings after the first of each entry
+	MostRewatched   *Entry                      `json:"most_rewatched"`   // entry watched the most times, nil if nothing was rewatched
+}
+
+/*
+Compute a detailed breakdown of a set of entries
+
+Params:
+
+	entries:	entries to compute stats for
+	loc:		timezone months are counted in
+
+Returns:
+
+	*Stats:	ptr to the stats
+*/
+func ComputeStats(entries []*Entry, loc *time.Location) *Stats {
+	stats := &Stats{
+		Summary:         Summarize(entries),
+		Categories:      make(map[Category]*CategoryStats),
+		RatingHistogram: make(map[int]int),
+		AddedPerMonth:   make(map[string]int),
+		DonePerMonth:    make(map[string]int),
+	}
+
+	for _, e := range entries {
+		c, ok := stats.Categories[e.Category]
+		if !ok {
+			c = &CategoryStats{}
+			stats.Categories[e.Category] = c
+		}
+		c.Total++
+
+		if e.Done {
+			c.Done++
+			if e.DoneDate != nil {
+				stats.DonePerMonth[e.DoneDate.In(loc).Format("2006-01")]++
+			}
+		} else if stats.LongestWaiting == nil || e.Date.Before(stats.LongestWaiting.Date) {
+			stats.LongestWaiting = e
+		}
+
+		if e.Rating > 0 {
+			// Running average, so we don't need a second pass
+			c.Rated++
+			c.AverageRating += (float64(e.Rating) - c.AverageRating) / float64(c.Rated)
+			stats.RatingHistogram[e.Rating]++
+		}
+
+		stats.AddedPerMonth[e.Date.In(loc).Format("2006-01")]++
+
+		stats.Watches += len(e.Watches)
+		stats.Rewatches += e.Rewatches()
+		if e.Rewatches() > 0 && (stats.MostRewatched == nil || len(e.Watches) > len(stats.MostRewatched.Watches)) {
+			stats.MostRewatched = e
+		}
+	}
+
+	return stats
+}
+
+// Last n months up to and including now's month, oldest first, ex. []string{"2024-11", "2024-12"}
+func lastMonths(now time.Time, n int) []string {
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	months := make([]string, n)
+	for i := range months {
+		months[i] = first.AddDate(0, i-n+1, 0).Format("2006-01")
+	}
+	return months
+}
+
+// Horizontal bar for text charts, scaled so the largest value fills width
+func textBar(value int, largest int, width int) string {
+	if largest == 0 || value == 0 {
+		return ""
+	}
+	return strings.Repeat("█", max(1, value*width/largest))
+}
+
+/*
+Render the stats as an embedded message with text charts
+
+Params:
+
+	title:	title of the embed
+	now:	current time, in the timezone dates are shown in
+
+Returns:
+
+	*discordgo.MessageEmbed:	ptr to the embed
+*/
+func (s *Stats) Embed(title string, now time.Time) *discordgo.MessageEmbed {
+	loc := now.Location()
+	block := func(lines []string) string {
+		if len(lines) == 0 {
+			return "-"
+		}
+		return "```\n" + strings.Join(lines, "\n") + "\n```"
+	}
+
+	overview := []string{
+		fmt.Sprintf("total      %4d", s.Total),
+		fmt.Sprintf("done       %4d (%.0f%%)", s.Done, s.CompletionRate()*100),
+		fmt.Sprintf("unwatched  %4d", s.Total-s.Done),
+		fmt.Sprintf("viewings   %4d", s.Watches),
+		fmt.Sprintf("rewatches  %4d", s.Rewatches),
+	}
+
+	var categories []string
+	for _, category := range []Category{Movie, Show, Anime} {
+		c, ok := s.Categories[category]
+		if !ok {
+			continue
+		}
+		line := fmt.Sprintf("%-6s %3d total %3d done", category, c.Total, c.Done)
+		if c.Rated > 0 {
+			line += fmt.Sprintf("  avg %.1f", c.AverageRating)
+		}
+		categories = append(categories, line)
+	}
+
+	// Highest rating first
+	var (
+		ratings    []string
+		ratingKeys []int
+		mostRated  int
+	)
+	for rating, n := range s.RatingHistogram {
+		ratingKeys = append(ratingKeys, rating)
+		mostRated = max(mostRated, n)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ratingKeys)))
+	for _, rating := range ratingKeys {
+		n := s.RatingHistogram[rating]
+		ratings = append(ratings, fmt.Sprintf("%2d %-10s %d", rating, textBar(n, mostRated, 10), n))
+	}
+
+	months := lastMonths(now, 12)
+	busiest := 0
+	for _, month := range months {
+		busiest = max(busiest, s.AddedPerMonth[month], s.DonePerMonth[month])
+	}
+	activity := []string{"month    added        done"}
+	for _, month := range months {
+		added, done := s.AddedPerMonth[month], s.DonePerMonth[month]
+		activity = append(activity, fmt.Sprintf("%s  %-8s %2d  %-8s %2d", month, textBar(added, busiest, 8), added, textBar(done, busiest, 8), done))
+	}
+
+	waiting := "nothing unwatched"
+	if e := s.LongestWaiting; e != nil {
+		days := int(now.Sub(e.Date).Hours() / 24)
+		waiting = fmt.Sprintf("**%s** (%s), added %s, %d days ago", e.DisplayTitle(), e.Category, e.Date.In(loc).Format("2006-01-02"), days)
+	}
+
+	rewatched := "nothing rewatched"
+	if e := s.MostRewatched; e != nil {
+		rewatched = fmt.Sprintf("**%s** (%s), watched %d times", e.DisplayTitle(), e.Category, len(e.Watches))
+		if first, last := e.WatchDates(); first != nil {
+			rewatched += fmt.Sprintf(", first %s, last %s", first.In(loc).Format("2006-01-02"), last.In(loc).Format("2006-01-02"))
+		}
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: title,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Overview", Value: block(overview)},
+			{Name: "By category", Value: block(categories)},
+			{Name: "Ratings", Value: block(ratings)},
+			{Name: "Last 12 months", Value: block(activity)},
+			{Name: "Waiting the longest", Value: waiting},
+			{Name: "Most rewatched", Value: rewatched},
+		},
+	}
+}
@@ -0,0 +1,187 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"log/slog"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+/*
+Add a tag to an entry in the database
+
+Tags are lowercased so filtering with tag:Horror and tag:horror is the same
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	tag:		tag to add
+
+Returns:
+
+	*Entry:	ptr to the tagged entry
+	error:	error object
+*/
+func TagEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, tag string) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tag = strings.ToLower(tag)
+	query := "INSERT OR IGNORE INTO tags(userID, title, category, year, tag) VALUES(?, ?, ?, ?, ?)"
+	_, err = tx.Exec(query, append(entry.key(), tag)...)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_TAG, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("tags.TagEntry", "user", userID, "title", entry.Title, "category", entry.Category, "tag", tag)
+	return entry, nil
+}
+
+/*
+Remove a tag from an entry in the database
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+	tag:		tag to remove
+
+Returns:
+
+	*Entry:	ptr to the untagged entry
+	error:	error object
+*/
+func UntagEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int, tag string) (*Entry, error) {
+	entry, err := findEntryWithDetails(db, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tag = strings.ToLower(tag)
+	query := "DELETE FROM tags WHERE " + ENTRY_KEY + " AND tag = ?"
+	_, err = tx.Exec(query, append(entry.key(), tag)...)
+	if err != nil {
+		return nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_UNTAG, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	slog.Debug("tags.UntagEntry", "user", userID, "title", entry.Title, "category", entry.Category, "tag", tag)
+	return entry, nil
+}
+
+/*
+Attach tags to the entries of a watchlist
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	error:	error object
+*/
+func (w *Watchlist) loadTags(db querier) error {
+	// Index entries by their key so each tag row is a single lookup,
+	// guild watchlists mix entries from several users
+	index := make(map[entryKey]*Entry, len(w.Entries))
+	users := make(map[string]bool)
+	for _, e := range w.Entries {
+		index[e.mapKey()] = e
+		users[e.UserID] = true
+	}
+
+	for userID := range users {
+		rows, err := db.Query("SELECT title, category, year, tag FROM tags WHERE userID = ? ORDER BY tag", userID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var (
+				tagged = Entry{UserID: userID}
+				tag    string
+			)
+			if err := rows.Scan(&tagged.Title, &tagged.Category, &tagged.Year, &tag); err != nil {
+				rows.Close()
+				return err
+			}
+
+			if e, ok := index[tagged.mapKey()]; ok {
+				e.Tags = append(e.Tags, tag)
+			}
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check if an entry has the given tag
+func (e *Entry) HasTag(tag string) bool {
+	tag = strings.ToLower(tag)
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
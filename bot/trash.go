@@ -0,0 +1,185 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// How long deleted entries stay in the trash before they're purged, set from the command line
+var TRASH_RETENTION = 30 * 24 * time.Hour
+
+/*
+Fetch the entries a user has in the trash
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	userID:	user ID to fetch the trash for
+
+Returns:
+
+	[]*Entry:	trashed entries, most recently deleted first
+	error:		error object
+*/
+func FetchTrash(db *sql.DB, userID string) ([]*Entry, error) {
+	query := "SELECT " + ENTRY_COLUMNS + " FROM entries WHERE userID = ? AND deleted IS NOT NULL ORDER BY deleted DESC"
+	return queryEntries(db, query, userID)
+}
+
+/*
+Restore an entry from the trash to its old position in the user's queue
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	actor:		who is making the change
+	userID:		user ID of the entry
+	title:		title of the entry
+	category:	category of the entry (empty string matches any category)
+	year:		release year of the entry (0 matches any year)
+
+Returns:
+
+	*Entry:	ptr to the restored entry
+	error:	NotInTrashError, AmbiguousEntryError or a database error
+*/
+func RestoreEntry(db *sql.DB, actor *Actor, userID string, title string, category Category, year int) (*Entry, error) {
+	entry, err := findEntry(db, true, userID, title, category, year)
+	if err != nil {
+		return nil, err
+	}
+	if err = (&Watchlist{Entries: []*Entry{entry}}).loadDetails(db); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = restore(tx, entry); err != nil {
+		return nil, err
+	}
+	if err = recordChange(tx, actor, ACTION_RESTORE, entry); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	entry.Deleted = nil
+	slog.Debug("trash.RestoreEntry", "user", userID, "title", entry.Title, "category", entry.Category, "year", entry.Year)
+	return entry, nil
+}
+
+// Take an entry out of the trash at its old position, entries purged in the meantime are inserted again
+func restore(tx *sql.Tx, e *Entry) error {
+	if err := e.makeRoom(tx); err != nil {
+		return err
+	}
+
+	query := "UPDATE entries SET deleted = NULL, priority = ? WHERE " + ENTRY_KEY + " AND deleted IS NOT NULL"
+	result, err := tx.Exec(query, append([]any{e.Priority}, e.key()...)...)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		if err = e.insert(tx); err != nil {
+			return err
+		}
+	}
+	return renumberQueue(tx, e.UserID)
+}
+
+/*
+Permanently delete entries that were trashed before a cutoff
+
+Their tags, links, availability and reminders are deleted with them by triggers.
+Trashed entries already left their owner's queue when they were deleted, so positions don't change.
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	before:	entries deleted before this time are purged
+
+Returns:
+
+	int64:	number of entries purged
+	error:	error object
+*/
+func PurgeTrash(db *sql.DB, before time.Time) (int64, error) {
+	// Load the entries first so the audit log has what was purged
+	expired, err := queryEntries(db, "SELECT "+ENTRY_COLUMNS+" FROM entries WHERE deleted IS NOT NULL AND deleted < ?", before.UTC())
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+	if err = (&Watchlist{Entries: expired}).loadDetails(db); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, e := range expired {
+		// Skip entries restored in the meantime
+		result, err := tx.Exec("DELETE FROM entries WHERE "+ENTRY_KEY+" AND deleted IS NOT NULL", e.key()...)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return 0, err
+		} else if n == 0 {
+			continue
+		}
+
+		if err = writeChange(tx, BOT_ACTOR, ACTION_PURGE, e, nil); err != nil {
+			return 0, err
+		}
+		purged++
+	}
+
+	return purged, tx.Commit()
+}
+
+// Scheduler job purging entries that have been in the trash longer than TRASH_RETENTION
+func purgeTrash(db *sql.DB, s *discordgo.Session, now time.Time) error {
+	purged, err := PurgeTrash(db, now.Add(-TRASH_RETENTION))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		slog.Info("trash.purgeTrash", "purged", purged)
+	}
+	return nil
+}
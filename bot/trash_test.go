@@ -0,0 +1,229 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// Titles in a user's queue, top first
+func queueTitles(t *testing.T, db *sql.DB, userID string) []string {
+	t.Helper()
+
+	watchlist, err := FetchWatchlist(db, ADMIN_VIEWER, userID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watchlist.Sort(SORT_PRIORITY)
+
+	var titles []string
+	for _, e := range watchlist.Entries {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+func TestRestoreEntry(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Heat", Category: Movie},
+	)
+	if _, err := TagEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, "sci-fi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Trashed entries are out of the watchlist and can't be added again
+	if got := queueTitles(t, db, "1"); !slices.Equal(got, []string{"Alien", "Heat"}) {
+		t.Errorf("queue after delete = %v", got)
+	}
+	trash, err := FetchTrash(db, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Title != "Dune" || trash[0].Deleted == nil {
+		t.Errorf("trash = %v, want Dune", trash)
+	}
+	var inTrash *EntryInTrashError
+	if err = (&Entry{UserID: "1", Title: "Dune", Category: Movie, Date: time.Now()}).Add(db, TEST_ACTOR); !errors.As(err, &inTrash) {
+		t.Errorf("add trashed entry = %v, want EntryInTrashError", err)
+	}
+
+	// Restoring puts it back where it was, with its tags
+	entry, err := RestoreEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Deleted != nil {
+		t.Errorf("restored entry still has a deleted date %v", entry.Deleted)
+	}
+	if got := queueTitles(t, db, "1"); !slices.Equal(got, []string{"Alien", "Dune", "Heat"}) {
+		t.Errorf("queue after restore = %v, want Dune back in the middle", got)
+	}
+	if dune := mustFindEntry(t, db, "1", "Dune", Movie); !dune.HasTag("sci-fi") {
+		t.Errorf("restored Dune has tags %v, want sci-fi", dune.Tags)
+	}
+
+	// Entries that aren't in the trash can't be restored
+	var notInTrash *NotInTrashError
+	if _, err = RestoreEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); !errors.As(err, &notInTrash) {
+		t.Errorf("restore of a watchlist entry = %v, want NotInTrashError", err)
+	}
+	if _, err = RestoreEntry(db, TEST_ACTOR, "1", "Jaws", Movie, 0); !errors.As(err, &notInTrash) {
+		t.Errorf("restore of a missing entry = %v, want NotInTrashError", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Heat", Category: Movie},
+	)
+	if _, err := TagEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0, "sci-fi"); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Alien", "Dune"} {
+		if err := DeleteEntry(db, TEST_ACTOR, "1", title, Movie, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Dune has been in the trash longer than the retention, Alien was just deleted
+	old := time.Now().Add(-TRASH_RETENTION - time.Hour).UTC()
+	if _, err := db.Exec("UPDATE entries SET deleted = ? WHERE title = 'Dune'", old); err != nil {
+		t.Fatal(err)
+	}
+	if err := purgeTrash(db, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := FetchTrash(db, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Title != "Alien" {
+		t.Errorf("trash after purge = %v, want only Alien", trash)
+	}
+	if got := queueTitles(t, db, "1"); !slices.Equal(got, []string{"Heat"}) {
+		t.Errorf("queue after purge = %v, want Heat", got)
+	}
+
+	// Purged entries take their details with them
+	var tags int
+	if err = db.QueryRow("SELECT COUNT(*) FROM tags WHERE title = 'Dune'").Scan(&tags); err != nil {
+		t.Fatal(err)
+	}
+	if tags != 0 {
+		t.Errorf("%d tags left for purged Dune, want 0", tags)
+	}
+	var notInTrash *NotInTrashError
+	if _, err = RestoreEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); !errors.As(err, &notInTrash) {
+		t.Errorf("restore of a purged entry = %v, want NotInTrashError", err)
+	}
+
+	// Undoing the delete brings a purged entry back from the journal
+	undone, err := Undo(db, TEST_ACTOR, "1", 2, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 2 {
+		t.Fatalf("undid %v, want both deletes", undone)
+	}
+	if got := queueTitles(t, db, "1"); !slices.Equal(got, []string{"Alien", "Dune", "Heat"}) {
+		t.Errorf("queue after undo = %v, want everything back in order", got)
+	}
+}
+
+// Positions in the queue stay contiguous as entries leave and come back, so relative moves work
+func TestQueuePositions(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Alien", Category: Movie},
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Heat", Category: Movie},
+		&Entry{UserID: "1", Title: "Jaws", Category: Movie},
+	)
+
+	positions := func() map[string]int {
+		t.Helper()
+
+		watchlist, err := FetchWatchlist(db, ADMIN_VIEWER, "1", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]int)
+		for _, e := range watchlist.Entries {
+			got[e.Title] = e.Priority
+		}
+		return got
+	}
+
+	if err := DeleteEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(); got["Alien"] != 1 || got["Heat"] != 2 || got["Jaws"] != 3 {
+		t.Errorf("positions after delete = %v, want Heat and Jaws moved up", got)
+	}
+
+	// Moving Jaws up one from #3 lands it at #2
+	jaws := mustFindEntry(t, db, "1", "Jaws", Movie)
+	if _, err := MoveEntry(db, TEST_ACTOR, "1", "Jaws", Movie, 0, jaws.Priority-1); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTitles(t, db, "1"); !slices.Equal(got, []string{"Alien", "Jaws", "Heat"}) {
+		t.Errorf("queue after moving Jaws up = %v", got)
+	}
+
+	if _, err := RestoreEntry(db, TEST_ACTOR, "1", "Dune", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := positions(); got["Alien"] != 1 || got["Dune"] != 2 || got["Jaws"] != 3 || got["Heat"] != 4 {
+		t.Errorf("positions after restore = %v, want 1 to 4 with Dune back at #2", got)
+	}
+}
+
+func TestQueueLength(t *testing.T) {
+	db := newTestDB(t)
+	addTestEntries(t, db,
+		&Entry{UserID: "1", Title: "Dune", Category: Movie},
+		&Entry{UserID: "1", Title: "Heat", Category: Movie},
+		&Entry{UserID: "2", Title: "Alien", Category: Movie},
+	)
+
+	// Entries in the trash aren't in the queue, so they don't make room for a position
+	if err := DeleteEntry(db, TEST_ACTOR, "1", "Heat", Movie, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for userID, want := range map[string]int{"1": 1, "2": 1, "3": 0} {
+		if n, err := queueLength(db, userID); err != nil || n != want {
+			t.Errorf("queueLength(%s) = %d, %v, want %d", userID, n, err, want)
+		}
+	}
+}
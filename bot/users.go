@@ -0,0 +1,183 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// User holds a user's personal settings
+type User struct {
+	UserID   string  `json:"user_id"`
+	Timezone string  `json:"timezone"` // IANA name, empty for the server's timezone
+	Privacy  Privacy `json:"privacy"`
+}
+
+/*
+Fetch a user's settings, users that never changed a setting get the defaults
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	userID:	user ID to fetch settings for
+
+Returns:
+
+	*User:	ptr to the user's settings
+	error:	error object
+*/
+func FetchUser(db *sql.DB, userID string) (*User, error) {
+	u := &User{UserID: userID, Privacy: DEFAULT_PRIVACY}
+	err := db.QueryRow("SELECT timezone, privacy FROM users WHERE userID = ?", userID).Scan(&u.Timezone, &u.Privacy)
+	if err == sql.ErrNoRows {
+		return u, nil
+	}
+	return u, err
+}
+
+/*
+Set a user's timezone
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	timezone:	IANA timezone name (ex. America/Edmonton)
+
+Returns:
+
+	error:	InvalidTimezoneError or a database error
+*/
+func (u *User) SetTimezone(db *sql.DB, timezone string) error {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" || timezone == "Local" {
+		return &InvalidTimezoneError{timezone}
+	}
+
+	query := "INSERT INTO users(userID, timezone) VALUES(?, ?) ON CONFLICT(userID) DO UPDATE SET timezone = excluded.timezone"
+	if _, err = db.Exec(query, u.UserID, loc.String()); err != nil {
+		return err
+	}
+
+	u.Timezone = loc.String()
+	slog.Debug("users.SetTimezone", "user", u.UserID, "timezone", u.Timezone)
+	return nil
+}
+
+/*
+Generate a new personal API token for the user, replacing any previous token
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	string:	the new token (only its hash is stored, so it can't be shown again)
+	error:	error object
+*/
+func (u *User) NewAPIToken(db *sql.DB) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret)
+
+	query := "INSERT INTO users(userID, tokenHash) VALUES(?, ?) ON CONFLICT(userID) DO UPDATE SET tokenHash = excluded.tokenHash"
+	if _, err := db.Exec(query, u.UserID, hashToken(token)); err != nil {
+		return "", err
+	}
+
+	slog.Debug("users.NewAPIToken", "user", u.UserID)
+	return token, nil
+}
+
+// Revoke the user's personal API token
+func (u *User) RevokeAPIToken(db *sql.DB) error {
+	_, err := db.Exec("UPDATE users SET tokenHash = '' WHERE userID = ?", u.UserID)
+	slog.Debug("users.RevokeAPIToken", "user", u.UserID)
+	return err
+}
+
+/*
+Find the user a personal API token belongs to
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	token:	API token sent by a client
+
+Returns:
+
+	*User:	ptr to the token's owner
+	error:	InvalidTokenError if no user has this token
+*/
+func FetchUserByToken(db *sql.DB, token string) (*User, error) {
+	if token == "" {
+		return nil, &InvalidTokenError{}
+	}
+
+	u := &User{}
+	err := db.QueryRow("SELECT userID, timezone, privacy FROM users WHERE tokenHash = ?", hashToken(token)).Scan(&u.UserID, &u.Timezone, &u.Privacy)
+	if err == sql.ErrNoRows {
+		return nil, &InvalidTokenError{}
+	}
+	return u, err
+}
+
+// Hex sha256 of a token, tokens are random so a plain hash is enough
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Timezone the user reads and writes dates in
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		slog.Warn("users.Location", "user", u.UserID, "error", err)
+		return time.Local
+	}
+	return loc
+}
+
+// Look up a user's timezone, falling back to the server's timezone on errors
+func userLocation(db *sql.DB, userID string) *time.Location {
+	u, err := FetchUser(db, userID)
+	if err != nil {
+		slog.Error("users.userLocation", "user", userID, "msg", err)
+		return time.Local
+	}
+	return u.Location()
+}
+
+// Format a time for display in a timezone, ex. Fri Dec 24 20:00 MST
+func formatTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("Mon Jan 2 15:04 MST")
+}
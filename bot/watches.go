@@ -0,0 +1,172 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Most viewings shown in an entry's info
+const WATCH_LOG_SIZE = 5
+
+// Watch is one viewing of an entry, rewatches get a watch each
+type Watch struct {
+	WatchID int64      `json:"watch_id"`
+	Date    *time.Time `json:"date,omitempty"`   // nil if watched before dates were recorded
+	Rating  int        `json:"rating,omitempty"` // the entry's rating for this viewing, 0 if unrated
+	Note    string     `json:"note,omitempty"`
+}
+
+// Add a viewing to an entry's watch log, as part of a transaction
+func (e *Entry) addWatch(tx *sql.Tx, w *Watch) error {
+	var date any
+	if w.Date != nil {
+		date = w.Date.UTC()
+	}
+
+	query := "INSERT INTO watches(userID, title, category, year, date, rating, note) VALUES(?, ?, ?, ?, ?, ?, ?)"
+	result, err := tx.Exec(query, append(e.key(), date, w.Rating, w.Note)...)
+	if err != nil {
+		return err
+	}
+	if w.WatchID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+
+	e.Watches = append(e.Watches, w)
+	return nil
+}
+
+// Remove the viewing logged after a snapshot of an entry, as part of a transaction
+func (e *Entry) unwatch(tx *sql.Tx) error {
+	var last int64
+	if len(e.Watches) > 0 {
+		last = e.Watches[len(e.Watches)-1].WatchID
+	}
+
+	query := "DELETE FROM watches WHERE watchID = (SELECT MAX(watchID) FROM watches WHERE " + ENTRY_KEY + ") AND watchID > ?"
+	_, err := tx.Exec(query, append(e.key(), last)...)
+	return err
+}
+
+// Put back the rating of a snapshot's latest viewing, as part of a transaction
+func (e *Entry) rewriteWatchRating(tx *sql.Tx) error {
+	if len(e.Watches) == 0 {
+		return nil
+	}
+	w := e.Watches[len(e.Watches)-1]
+
+	_, err := tx.Exec("UPDATE watches SET rating = ? WHERE watchID = ?", w.Rating, w.WatchID)
+	return err
+}
+
+// Number of times an entry was watched after the first time
+func (e *Entry) Rewatches() int {
+	return max(0, len(e.Watches)-1)
+}
+
+// First and last dates an entry was watched on, nil if it wasn't watched or only before dates were recorded
+func (e *Entry) WatchDates() (first *time.Time, last *time.Time) {
+	for _, w := range e.Watches {
+		if w.Date == nil {
+			continue
+		}
+		if first == nil || w.Date.Before(*first) {
+			first = w.Date
+		}
+		if last == nil || w.Date.After(*last) {
+			last = w.Date
+		}
+	}
+	return first, last
+}
+
+// Describe a viewing, ex. "Sat Dec 14 20:00 MST, rated 8: better on the big screen"
+func (w *Watch) Format(loc *time.Location) string {
+	text := "before dates were recorded"
+	if w.Date != nil {
+		text = formatTime(*w.Date, loc)
+	}
+	if w.Rating > 0 {
+		text += fmt.Sprintf(", rated %d", w.Rating)
+	}
+	if w.Note != "" {
+		text += ": " + w.Note
+	}
+	return text
+}
+
+/*
+Attach the watch log to the entries of a watchlist
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+
+Returns:
+
+	error:	error object
+*/
+func (w *Watchlist) loadWatches(db querier) error {
+	// Same indexing as loadTags
+	index := make(map[entryKey]*Entry, len(w.Entries))
+	users := make(map[string]bool)
+	for _, e := range w.Entries {
+		e.Watches = nil
+		index[e.mapKey()] = e
+		users[e.UserID] = true
+	}
+
+	for userID := range users {
+		rows, err := db.Query("SELECT title, category, year, watchID, date, rating, note FROM watches WHERE userID = ? ORDER BY watchID", userID)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var (
+				watched = Entry{UserID: userID}
+				watch   Watch
+				date    sql.NullTime
+			)
+			if err := rows.Scan(&watched.Title, &watched.Category, &watched.Year, &watch.WatchID, &date, &watch.Rating, &watch.Note); err != nil {
+				rows.Close()
+				return err
+			}
+
+			if date.Valid {
+				watch.Date = &date.Time
+			}
+			if e, ok := index[watched.mapKey()]; ok {
+				e.Watches = append(e.Watches, &watch)
+			}
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
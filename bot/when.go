@@ -0,0 +1,179 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hour used when a date is given without a time (ex. "friday" means friday at 20:00)
+const DEFAULT_HOUR = 20
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var durationUnits = map[string]time.Duration{
+	"minute": time.Minute, "minutes": time.Minute, "min": time.Minute, "mins": time.Minute,
+	"hour": time.Hour, "hours": time.Hour, "hr": time.Hour, "hrs": time.Hour,
+	"day": 24 * time.Hour, "days": 24 * time.Hour,
+	"week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+/*
+Parse a human friendly date into a time in the future
+
+Accepted formats (case insensitive):
+  - in <n> <minutes/hours/days/weeks>		ex. in 2 hours
+  - <day> <time?>							ex. tomorrow 8pm, friday 20:00, today, tonight
+  - <time>									ex. 20:00, 8:30pm (today, or tomorrow if it has passed)
+  - <yyyy-mm-dd> <time?>					ex. 2024-12-24 18:00
+
+Params:
+
+	input:	date to parse
+	now:	reference time, relative dates are resolved from here
+	loc:	timezone the input is written in
+
+Returns:
+
+	time.Time:	parsed time
+	error:		InvalidDateError if the input can't be parsed
+*/
+func ParseWhen(input string, now time.Time, loc *time.Location) (time.Time, error) {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 {
+		return time.Time{}, &InvalidDateError{input}
+	}
+	now = now.In(loc)
+
+	// case: in <n> <unit>
+	if fields[0] == "in" && len(fields) == 3 {
+		n, err := strconv.Atoi(fields[1])
+		unit, ok := durationUnits[fields[2]]
+		if err != nil || !ok || n <= 0 {
+			return time.Time{}, &InvalidDateError{input}
+		}
+		return now.Add(time.Duration(n) * unit), nil
+	}
+
+	// Split into a day part and a time part, either of which may be missing
+	var (
+		day       time.Time
+		dayGiven  bool
+		dayFields = fields[:len(fields)-1]
+		hour, min int
+	)
+	if h, m, ok := parseClock(fields[len(fields)-1]); ok {
+		hour, min = h, m
+	} else {
+		dayFields = fields
+		hour = DEFAULT_HOUR
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch len(dayFields) {
+	case 0:
+		day = midnight
+	case 1:
+		dayGiven = true
+		name := dayFields[0]
+		if wd, ok := weekdays[name]; ok {
+			// Next occurrence of the weekday, today counts if the time hasn't passed
+			offset := (int(wd) - int(now.Weekday()) + 7) % 7
+			day = midnight.AddDate(0, 0, offset)
+			if offset == 0 && !day.Add(time.Duration(hour)*time.Hour+time.Duration(min)*time.Minute).After(now) {
+				day = day.AddDate(0, 0, 7)
+			}
+			break
+		}
+
+		switch name {
+		case "today", "tonight":
+			day = midnight
+		case "tomorrow":
+			day = midnight.AddDate(0, 0, 1)
+		default:
+			d, err := time.ParseInLocation("2006-01-02", name, loc)
+			if err != nil {
+				return time.Time{}, &InvalidDateError{input}
+			}
+			day = d
+		}
+	default:
+		return time.Time{}, &InvalidDateError{input}
+	}
+
+	when := time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, loc)
+
+	// A bare time that has already passed today means tomorrow
+	if !dayGiven && !when.After(now) {
+		when = when.AddDate(0, 0, 1)
+	}
+
+	if !when.After(now) {
+		return time.Time{}, &InvalidDateError{input}
+	}
+	return when, nil
+}
+
+// Parse a time of day like 20:00, 8pm or 8:30pm
+func parseClock(s string) (hour int, min int, ok bool) {
+	pm := strings.HasSuffix(s, "pm")
+	am := strings.HasSuffix(s, "am")
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "pm"), "am")
+
+	h, m, found := strings.Cut(s, ":")
+	hour, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, false
+	}
+	if found {
+		if min, err = strconv.Atoi(m); err != nil || len(m) != 2 {
+			return 0, 0, false
+		}
+	} else if !am && !pm {
+		// A bare number is ambiguous with titles and counts, require 8pm or 20:00
+		return 0, 0, false
+	}
+
+	// Convert 12-hour clock to 24-hour clock
+	if am || pm {
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+
+	if hour < 0 || hour > 23 || min < 0 || min > 59 {
+		return 0, 0, false
+	}
+	return hour, min, true
+}
@@ -0,0 +1,133 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWhen(t *testing.T) {
+	// Wednesday afternoon, 7 hours behind UTC
+	loc := time.FixedZone("MST", -7*60*60)
+	now := time.Date(2024, 12, 18, 15, 0, 0, 0, loc)
+	at := func(month time.Month, day int, hour int, min int) time.Time {
+		return time.Date(2024, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		// Relative
+		{"in 2 hours", now.Add(2 * time.Hour)},
+		{"in 30 mins", now.Add(30 * time.Minute)},
+		{"in 1 day", now.AddDate(0, 0, 1)},
+		{"in 2 weeks", now.AddDate(0, 0, 14)},
+
+		// Named days, at DEFAULT_HOUR without a time
+		{"tomorrow 8pm", at(12, 19, 20, 0)},
+		{"tomorrow", at(12, 19, DEFAULT_HOUR, 0)},
+		{"today", at(12, 18, DEFAULT_HOUR, 0)},
+		{"tonight 9:30pm", at(12, 18, 21, 30)},
+		{"TOMORROW 8PM", at(12, 19, 20, 0)},
+
+		// Weekdays, today only counts if the time hasn't passed
+		{"friday 20:00", at(12, 20, 20, 0)},
+		{"fri", at(12, 20, DEFAULT_HOUR, 0)},
+		{"monday 9am", at(12, 23, 9, 0)},
+		{"wednesday", at(12, 18, DEFAULT_HOUR, 0)},
+		{"wed 9am", at(12, 25, 9, 0)},
+
+		// Bare times, tomorrow if they've passed today
+		{"20:00", at(12, 18, 20, 0)},
+		{"8:30am", at(12, 19, 8, 30)},
+		{"12pm", at(12, 19, 12, 0)},
+		{"12am", at(12, 19, 0, 0)},
+
+		// Dates
+		{"2024-12-24 18:00", at(12, 24, 18, 0)},
+		{"2024-12-24", at(12, 24, DEFAULT_HOUR, 0)},
+		{"2025-01-01 12am", time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, test := range tests {
+		got, err := ParseWhen(test.input, now, loc)
+		if err != nil {
+			t.Errorf("ParseWhen(%q): %v", test.input, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("ParseWhen(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseWhenInvalid(t *testing.T) {
+	loc := time.FixedZone("MST", -7*60*60)
+	now := time.Date(2024, 12, 18, 15, 0, 0, 0, loc)
+
+	inputs := []string{
+		"",
+		"someday",
+		"in 0 days",
+		"in -2 hours",
+		"in 2 fortnights",
+		"in two hours",
+		"20",         // bare numbers aren't times
+		"13pm",       // not a 12-hour time
+		"25:00",      // not a 24-hour time
+		"8:5pm",      // minutes need two digits
+		"today 9am",  // already passed
+		"2024-12-01", // in the past
+		"next friday 8pm",
+		"2024-13-01",
+	}
+	for _, input := range inputs {
+		got, err := ParseWhen(input, now, loc)
+		var invalid *InvalidDateError
+		if !errors.As(err, &invalid) {
+			t.Errorf("ParseWhen(%q) = %v, %v, want InvalidDateError", input, got, err)
+		}
+	}
+}
+
+// Dates are read in the user's timezone, not the server's
+func TestParseWhenTimezone(t *testing.T) {
+	now := time.Date(2024, 12, 18, 22, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		offset int // hours from UTC
+		want   time.Time
+	}{
+		{0, time.Date(2024, 12, 19, 20, 0, 0, 0, time.UTC)},
+		{-7, time.Date(2024, 12, 20, 3, 0, 0, 0, time.UTC)},
+		{9, time.Date(2024, 12, 20, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		loc := time.FixedZone("test", test.offset*60*60)
+		got, err := ParseWhen("tomorrow 8pm", now, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("offset %d: got %v, want %v", test.offset, got.UTC(), test.want)
+		}
+	}
+}
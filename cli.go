@@ -0,0 +1,305 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ttamre/watchlist/bot"
+)
+
+const CLI_USAGE = `usage: watchlist [-database path] cli <command> --user <id> [options] [args]
+
+commands:
+  add     [--position n] [--link url] [--year yyyy] <title> <category>
+  delete  <title> [category]
+  update  <title> [category] <link>
+  done    [--note text] <title> [category]  (again to log a rewatch)
+  rate    <title> [category] <rating>  (1-10)
+  undo    [count]  (the last deletes, completions, ratings and link updates)
+  trash   (deleted entries, kept until they're purged)
+  restore <title> [category]
+  history <title> [category]  (changes to the entry, newest first)
+  view    [--sort title/date/category/priority] [--unwatched] [--json]
+  export  (all entries as JSON)
+
+titles like "Dune (2021)" pick out one release year`
+
+/*
+Run a command against the database without connecting to discord
+
+Params:
+
+	db:		ptr to sqlite3 database connection
+	args:	arguments after "cli", ex. []string{"view", "--user", "1234", "--json"}
+	out:	where to print results
+
+Returns:
+
+	error:	error object
+*/
+func runCLI(db *sql.DB, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", CLI_USAGE)
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	userID := flags.String("user", "", "discord user ID that owns the watchlist")
+	position := flags.Int("position", 0, "position in the watchlist (add)")
+	link := flags.String("link", "", "link to a trailer/imdb/etc (add)")
+	year := flags.Int("year", 0, "release year (add)")
+	sortBy := flags.String("sort", string(bot.SORT_PRIORITY), "sort order (view)")
+	unwatched := flags.Bool("unwatched", false, "only show unwatched entries (view)")
+	asJSON := flags.Bool("json", false, "print JSON instead of a table (view)")
+	note := flags.String("note", "", "note about the viewing (done)")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return fmt.Errorf("--user is required\n%s", CLI_USAGE)
+	}
+	rest := flags.Args()
+
+	// Changes made from the cli are made by the bot's operator, not the user
+	actor := &bot.Actor{Source: bot.SOURCE_CLI}
+
+	switch command {
+	case bot.ADD_COMMAND:
+		if len(rest) < 2 {
+			return fmt.Errorf("%s", CLI_USAGE)
+		}
+
+		entry := &bot.Entry{
+			UserID:   *userID,
+			Title:    rest[0],
+			Category: bot.Category(rest[1]),
+			Year:     *year,
+			Date:     time.Now(),
+			Priority: *position,
+		}
+		if err := entry.IsValid(); err != nil {
+			return err
+		}
+		if *link != "" {
+			if _, err := entry.AddLink(*link, ""); err != nil {
+				return err
+			}
+		}
+		if _, err := entry.Enrich(bot.METADATA_PROVIDER); err != nil {
+			slog.Error("cli.add", "msg", err)
+		}
+		if err := entry.Add(db, actor); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "added %s at #%d\n", entry.DisplayTitle(), entry.Priority)
+
+	case bot.DELETE_COMMAND, bot.DONE_COMMAND:
+		entry, err := findCLIEntry(db, *userID, rest, 0)
+		if err != nil {
+			return err
+		}
+
+		if command == bot.DELETE_COMMAND {
+			err = bot.DeleteEntry(db, actor, *userID, entry.Title, entry.Category, entry.Year)
+		} else {
+			entry, err = bot.DoneEntry(db, actor, *userID, entry.Title, entry.Category, entry.Year, *note)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s %s\n", command, entry.DisplayTitle())
+		if entry.Rewatches() > 0 {
+			fmt.Fprintf(out, "watched %d times\n", len(entry.Watches))
+		}
+
+	case bot.UPDATE_COMMAND, bot.RATE_COMMAND:
+		if len(rest) < 2 {
+			return fmt.Errorf("%s", CLI_USAGE)
+		}
+		value := rest[len(rest)-1]
+
+		entry, err := findCLIEntry(db, *userID, rest, 1)
+		if err != nil {
+			return err
+		}
+
+		if command == bot.UPDATE_COMMAND {
+			var link *bot.Link
+			if _, link, err = bot.UpdateEntry(db, actor, *userID, entry.Title, entry.Category, entry.Year, value); err == nil {
+				value = link.URL
+			}
+		} else {
+			rating, convErr := strconv.Atoi(value)
+			if convErr != nil {
+				return fmt.Errorf("invalid rating: %s", value)
+			}
+			err = bot.RateEntry(db, actor, *userID, entry.Title, entry.Category, entry.Year, rating)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "%s %s -> %s\n", command, entry.DisplayTitle(), value)
+
+	case bot.UNDO_COMMAND:
+		count := 1
+		if len(rest) > 0 {
+			n, err := strconv.Atoi(rest[0])
+			if err != nil {
+				return fmt.Errorf("invalid count: %s", rest[0])
+			}
+			count = n
+		}
+
+		undone, err := bot.Undo(db, actor, *userID, count, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, j := range undone {
+			fmt.Fprintf(out, "undid %s\n", j)
+		}
+
+	case bot.TRASH_COMMAND:
+		trash, err := bot.FetchTrash(db, *userID)
+		if err != nil {
+			return err
+		}
+
+		user, err := bot.FetchUser(db, *userID)
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "TITLE\tCATEGORY\tDELETED\tPURGED")
+		for _, e := range trash {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", e.DisplayTitle(), e.Category,
+				e.Deleted.In(user.Location()).Format("2006-01-02"), e.Deleted.Add(bot.TRASH_RETENTION).In(user.Location()).Format("2006-01-02"))
+		}
+		table.Flush()
+
+	case bot.RESTORE_COMMAND:
+		if len(rest) == 0 {
+			return fmt.Errorf("%s", CLI_USAGE)
+		}
+		title, category, year := cliTarget(rest)
+
+		entry, err := bot.RestoreEntry(db, actor, *userID, title, category, year)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "restored %s at #%d\n", entry.DisplayTitle(), entry.Priority)
+
+	case bot.HISTORY_COMMAND:
+		if len(rest) == 0 {
+			return fmt.Errorf("%s", CLI_USAGE)
+		}
+		title, category, year := cliTarget(rest)
+
+		changes, err := bot.FetchHistory(db, *userID, title, category, year)
+		if err != nil {
+			return err
+		}
+
+		table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "DATE\tACTOR\tSOURCE\tACTION\tCHANGE")
+		for _, c := range changes {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", c.Date.Format(time.RFC3339), c.Actor.UserID, c.Actor.Source, c.Action, c.Summary())
+		}
+		table.Flush()
+
+	case bot.VIEW_COMMAND, "export":
+		watchlist, err := bot.FetchWatchlist(db, bot.ADMIN_VIEWER, *userID, command == "export" || !*unwatched)
+		if err != nil {
+			return err
+		}
+
+		sort := bot.SortBy(*sortBy)
+		if err := sort.IsValid(); err != nil {
+			return err
+		}
+		watchlist.Sort(sort)
+
+		if command == "export" || *asJSON {
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(watchlist)
+		}
+
+		user, err := bot.FetchUser(db, *userID)
+		if err != nil {
+			return err
+		}
+		printTable(out, watchlist, user.Location())
+
+	default:
+		return fmt.Errorf("unknown command: %s\n%s", command, CLI_USAGE)
+	}
+
+	return nil
+}
+
+// Find the entry named by "<title> [category]" followed by trailing value arguments
+func findCLIEntry(db *sql.DB, userID string, args []string, trailing int) (*bot.Entry, error) {
+	args = args[:len(args)-trailing]
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s", CLI_USAGE)
+	}
+
+	title, category, year := cliTarget(args)
+	return bot.FindEntry(db, bot.ADMIN_VIEWER, userID, title, category, year)
+}
+
+// Split "<title> [category]" arguments into a title, category and release year
+func cliTarget(args []string) (string, bot.Category, int) {
+	var category bot.Category
+	if len(args) >= 2 {
+		category = bot.Category(args[1])
+	}
+	title, year := bot.SplitYear(args[0])
+	return title, category, year
+}
+
+// Print a watchlist as an aligned table, with dates in the owner's timezone
+func printTable(out io.Writer, watchlist *bot.Watchlist, loc *time.Location) {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "#\tTITLE\tCATEGORY\tDONE\tRATING\tADDED\tLINK")
+	for _, e := range watchlist.Entries {
+		done := ""
+		if e.Done {
+			done = "yes"
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.Priority, e.DisplayTitle(), e.Category, done, e.Rating, e.Date.In(loc).Format("2006-01-02"), e.Link)
+	}
+	table.Flush()
+}
@@ -19,32 +19,73 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
-	"database/sql"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	_ "time/tzdata" // timezone database for systems without one, used for per-user timezones
 
 	"github.com/bwmarrin/discordgo"
 	_ "github.com/mattn/go-sqlite3"
 
 	"github.com/ttamre/watchlist/bot"
+	"github.com/ttamre/watchlist/web"
 )
 
-const DEFAULT_DB_PATH = "data/database.db"
+const (
+	DEFAULT_DB_PATH       = "data/database.db"
+	DEFAULT_METADATA_PATH = "data/metadata.json"
+)
 
 func main() {
 	// Process command line flags
 	db_path := flag.String("database", DEFAULT_DB_PATH, "database file path")
+	http_addr := flag.String("http", "", "address to serve the HTTP API and share pages on (ex. :8080), disabled if empty")
+	public_url := flag.String("url", bot.PUBLIC_URL, "public base URL of the HTTP server, used in share links")
+	trash_retention := flag.Duration("retention", bot.TRASH_RETENTION, "how long deleted entries stay in the trash before they're purged")
+	metadata_path := flag.String("metadata", DEFAULT_METADATA_PATH, "JSON dataset used to look up titles when they're added, disabled if empty")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: watchlist [-database path] [cli <command> ...]\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s\n", CLI_USAGE)
+	}
 	flag.Parse()
+	bot.PUBLIC_URL = strings.TrimSuffix(*public_url, "/")
+	bot.TRASH_RETENTION = *trash_retention
 
 	// Creating a database connectioni
-	db, err := sql.Open("sqlite3", *db_path)
+	db, err := bot.OpenDatabase(*db_path)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer db.Close()
 
+	// Look up new entries and where they're streaming in the local metadata dataset, entries are kept as typed without it
+	if *metadata_path != "" {
+		provider, err := bot.NewFixtureProvider(*metadata_path)
+		if err != nil {
+			log.Printf("metadata lookups disabled: %s", err)
+		} else {
+			bot.METADATA_PROVIDER = provider
+			bot.AVAILABILITY_PROVIDER = provider
+		}
+	}
+
+	// Bring the database schema up to date
+	if err = bot.Migrate(db); err != nil {
+		log.Fatal(err)
+	}
+
+	// Standalone mode: run a single command against the database and exit
+	if flag.Arg(0) == "cli" {
+		if err = runCLI(db, flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Creating a session to connect to discord server
 	session, err := discordgo.New("Bot " + os.Getenv("DISCORD_WATCHLIST_BOT_TOKEN"))
 	if err != nil {
@@ -55,6 +96,9 @@ func main() {
 	session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
 		bot.MasterHandler(db, s, m)
 	})
+	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		bot.InteractionHandler(db, s, i)
+	})
 
 	// Open a websocket connection to Discord and begin listening.
 	err = session.Open()
@@ -64,6 +108,16 @@ func main() {
 	}
 	defer session.Close()
 
+	// Start sending reminders and other scheduled messages
+	bot.StartScheduler(db, session)
+
+	// Optionally serve the HTTP API and share pages alongside the bot
+	if *http_addr != "" {
+		go func() {
+			log.Fatal(web.ListenAndServe(*http_addr, db, os.Getenv("WATCHLIST_API_TOKEN")))
+		}()
+	}
+
 	// Simple way to keep program running until CTRL-C is pressed
 	fmt.Println("bot is now running, press ctrl-c to exit...")
 	<-make(chan struct{})
@@ -0,0 +1,269 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ttamre/watchlist/bot"
+)
+
+// Register the JSON API routes, every route works on the watchlist of {userID}
+func (srv *Server) registerAPI() {
+	const entry = "/api/users/{userID}/entries/{category}/{title}"
+
+	srv.mux.Handle("GET /api/users/{userID}/entries", srv.api(srv.listEntries))
+	srv.mux.Handle("POST /api/users/{userID}/entries", srv.api(srv.addEntry))
+	srv.mux.Handle("GET "+entry, srv.api(srv.getEntry))
+	srv.mux.Handle("PATCH "+entry, srv.api(srv.updateEntry))
+	srv.mux.Handle("DELETE "+entry, srv.api(srv.deleteEntry))
+	srv.mux.Handle("POST "+entry+"/done", srv.api(srv.doneEntry))
+	srv.mux.Handle("PUT "+entry+"/rating", srv.api(srv.rateEntry))
+}
+
+/*
+List a user's watchlist
+
+	GET /api/users/{userID}/entries?sort=<title/date/category/priority>&unwatched=<true/false>
+
+Responds with the Watchlist JSON
+*/
+func (srv *Server) listEntries(w http.ResponseWriter, r *http.Request, userID string) error {
+	sortBy := bot.SORT_PRIORITY
+	if s := r.URL.Query().Get("sort"); s != "" {
+		sortBy = bot.SortBy(s)
+	}
+	if err := sortBy.IsValid(); err != nil {
+		return err
+	}
+
+	watchlist, err := bot.FetchWatchlist(srv.db, viewerOf(r), userID, r.URL.Query().Get("unwatched") != "true")
+	if err != nil {
+		return err
+	}
+	watchlist.Sort(sortBy)
+
+	writeJSON(w, http.StatusOK, watchlist)
+	return nil
+}
+
+/*
+Add an entry to a user's watchlist
+
+	POST /api/users/{userID}/entries
+	{"title": "The Godfather", "category": "movie", "year": 1972, "link": "...", "priority": 1, "keep_both": false}
+
+Responds 201 with the Entry JSON. Responds 409 with {"error": "...", "existing": Entry} if it looks like an
+entry already on the watchlist (ex. another release or category of the same title), "keep_both" adds it anyway
+*/
+func (srv *Server) addEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	var body struct {
+		Title    string       `json:"title"`
+		Category bot.Category `json:"category"`
+		Year     int          `json:"year"`
+		Link     string       `json:"link"`
+		Priority int          `json:"priority"`
+		KeepBoth bool         `json:"keep_both"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		return err
+	}
+
+	entry := &bot.Entry{
+		UserID:   userID,
+		Title:    body.Title,
+		Category: body.Category,
+		Year:     body.Year,
+		Date:     time.Now(),
+		Priority: body.Priority,
+	}
+	if err := entry.IsValid(); err != nil {
+		return err
+	}
+	if body.Link != "" {
+		if _, err := entry.AddLink(body.Link, ""); err != nil {
+			return err
+		}
+	}
+	if _, err := entry.Enrich(bot.METADATA_PROVIDER); err != nil {
+		slog.Error("api.addEntry", "msg", err)
+	}
+
+	// The same duplicate check as ./watchlist add, keeping both still can't add an exact duplicate
+	duplicates, err := bot.FindDuplicates(srv.db, entry)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 && !body.KeepBoth {
+		existing := duplicates[0]
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error":    fmt.Sprintf("%s looks like %s, already on the watchlist at #%d", entry.DisplayTitle(), existing.DisplayTitle(), existing.Priority),
+			"existing": existing,
+		})
+		return nil
+	}
+
+	if err := entry.Add(srv.db, actorOf(r)); err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusCreated, entry)
+	return nil
+}
+
+/*
+Get a single entry
+
+	GET /api/users/{userID}/entries/{category}/{title}
+
+Responds with the Entry JSON
+*/
+func (srv *Server) getEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	entry, err := srv.findEntry(r, userID)
+	if err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+/*
+Update the link of an entry
+
+	PATCH /api/users/{userID}/entries/{category}/{title}
+	{"link": "https://www.imdb.com/title/tt0068646/"}
+
+Links to known sites are canonicalized, and replace the entry's link of the same kind.
+Responds with the updated Entry JSON
+*/
+func (srv *Server) updateEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	var body struct {
+		Link string `json:"link"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		return err
+	}
+
+	entry, err := srv.findEntry(r, userID)
+	if err != nil {
+		return err
+	}
+	if entry, _, err = bot.UpdateEntry(srv.db, actorOf(r), userID, entry.Title, entry.Category, entry.Year, body.Link); err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+/*
+Delete an entry
+
+	DELETE /api/users/{userID}/entries/{category}/{title}
+
+Responds 204
+*/
+func (srv *Server) deleteEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	entry, err := srv.findEntry(r, userID)
+	if err != nil {
+		return err
+	}
+	if err = bot.DeleteEntry(srv.db, actorOf(r), userID, entry.Title, entry.Category, entry.Year); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+/*
+Mark an entry as done, marking it done again logs a rewatch
+
+	POST /api/users/{userID}/entries/{category}/{title}/done
+	{"note": "better the second time"}	(optional)
+
+Responds with the updated Entry JSON
+*/
+func (srv *Server) doneEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	var body struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &body); err != nil {
+			return err
+		}
+	}
+
+	entry, err := srv.findEntry(r, userID)
+	if err != nil {
+		return err
+	}
+	if entry, err = bot.DoneEntry(srv.db, actorOf(r), userID, entry.Title, entry.Category, entry.Year, body.Note); err != nil {
+		return err
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+/*
+Rate an entry
+
+	PUT /api/users/{userID}/entries/{category}/{title}/rating
+	{"rating": 5}
+
+Responds with the updated Entry JSON
+*/
+func (srv *Server) rateEntry(w http.ResponseWriter, r *http.Request, userID string) error {
+	var body struct {
+		Rating *int `json:"rating"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		return err
+	}
+	if body.Rating == nil {
+		return &badRequestError{"missing rating"}
+	}
+
+	entry, err := srv.findEntry(r, userID)
+	if err != nil {
+		return err
+	}
+	if err = bot.RateEntry(srv.db, actorOf(r), userID, entry.Title, entry.Category, entry.Year, *body.Rating); err != nil {
+		return err
+	}
+
+	entry.Rating = *body.Rating
+	writeJSON(w, http.StatusOK, entry)
+	return nil
+}
+
+// Find the entry named by the {category} and {title} path values, a title like "Dune (2021)" picks a release year
+func (srv *Server) findEntry(r *http.Request, userID string) (*bot.Entry, error) {
+	category := bot.Category(r.PathValue("category"))
+	if err := category.IsValid(); err != nil {
+		return nil, err
+	}
+	title, year := bot.SplitYear(r.PathValue("title"))
+	return bot.FindEntry(srv.db, viewerOf(r), userID, title, category, year)
+}
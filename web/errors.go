@@ -0,0 +1,42 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package web
+
+import "fmt"
+
+/* STRUCTS */
+
+type badRequestError struct {
+	message string
+}
+
+type forbiddenError struct {
+	userID  string
+	ownerID string
+}
+
+/* CLASS METHODS */
+
+func (e *badRequestError) Error() string {
+	return fmt.Sprintf("Bad request: %s", e.message)
+}
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("%s can't access the watchlist of %s", e.userID, e.ownerID)
+}
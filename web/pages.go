@@ -0,0 +1,211 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package web
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ttamre/watchlist/bot"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"join":    strings.Join,
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// Filters for the share page, also used for the status dropdown
+const (
+	STATUS_ALL       = "all"
+	STATUS_UNWATCHED = "unwatched"
+	STATUS_DONE      = "done"
+)
+
+// Data rendered by templates/watchlist.html
+type watchlistPage struct {
+	Heading string
+	Guild   bool
+	Summary *bot.Summary
+	Rows    []*watchlistRow
+
+	// Current filters and the options offered for each
+	Sort          bot.SortBy
+	Category      bot.Category
+	Status        string
+	Tag           string
+	SortOptions   []bot.SortBy
+	Categories    []bot.Category
+	StatusOptions []string
+}
+
+// An entry as shown in the table
+type watchlistRow struct {
+	*bot.Entry
+	Owner string // username of the entry's owner, for guild watchlists
+	Added string // date added, in the owner's timezone
+}
+
+// Register the HTML page routes
+func (srv *Server) registerPages() {
+	srv.mux.HandleFunc("GET /share/{shareID}", srv.sharePage)
+}
+
+/*
+Render a shared watchlist as a read-only HTML page
+
+	GET /share/{shareID}?sort=<sort>&category=<category>&status=<all/unwatched/done>&tag=<tag>
+
+The share ID is the only credential, links stop working once revoked
+*/
+func (srv *Server) sharePage(w http.ResponseWriter, r *http.Request) {
+	share, err := bot.FetchShare(srv.db, r.PathValue("shareID"))
+	if err != nil {
+		var notFound *bot.ShareNotFoundError
+		if errors.As(err, &notFound) {
+			http.Error(w, "this link doesn't exist or was revoked", http.StatusNotFound)
+			return
+		}
+		slog.Error("pages.sharePage", "msg", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	var private *bot.PrivateWatchlistError
+	page, err := srv.buildWatchlistPage(share, r)
+	if errors.As(err, &private) {
+		http.Error(w, "this watchlist isn't shared publicly", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		slog.Error("pages.sharePage", "share", share.ShareID, "msg", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer") // keep the share ID out of other sites' logs
+	if err := templates.ExecuteTemplate(w, "watchlist.html", page); err != nil {
+		slog.Error("pages.sharePage", "share", share.ShareID, "msg", err)
+	}
+}
+
+// Load the shared watchlist and apply the filters from the query string
+func (srv *Server) buildWatchlistPage(share *bot.Share, r *http.Request) (*watchlistPage, error) {
+	query := r.URL.Query()
+	page := &watchlistPage{
+		Guild:         share.GuildID != "",
+		Sort:          bot.SortBy(query.Get("sort")),
+		Category:      bot.Category(query.Get("category")),
+		Status:        query.Get("status"),
+		Tag:           query.Get("tag"),
+		SortOptions:   []bot.SortBy{bot.SORT_PRIORITY, bot.SORT_TITLE, bot.SORT_DATE, bot.SORT_CATEGORY},
+		Categories:    []bot.Category{bot.Movie, bot.Show, bot.Anime},
+		StatusOptions: []string{STATUS_ALL, STATUS_UNWATCHED, STATUS_DONE},
+	}
+
+	// Fall back to defaults for anything invalid rather than failing the page
+	if page.Sort.IsValid() != nil {
+		page.Sort = bot.SORT_PRIORITY
+		if page.Guild {
+			page.Sort = bot.SORT_TITLE // priorities are per user, they don't mix
+		}
+	}
+	if page.Category != "" && page.Category.IsValid() != nil {
+		page.Category = ""
+	}
+	if page.Status != STATUS_UNWATCHED && page.Status != STATUS_DONE {
+		page.Status = STATUS_ALL
+	}
+
+	var (
+		watchlist *bot.Watchlist
+		err       error
+		usernames = make(map[string]string)
+		locations = make(map[string]*time.Location)
+	)
+	if page.Guild {
+		watchlist, err = bot.FetchGuildWatchlist(srv.db, share.GuildID, bot.PRIVACY_LINK, true)
+		if err != nil {
+			return nil, err
+		}
+
+		members, err := bot.FetchMembers(srv.db, share.GuildID)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			usernames[m.UserID] = m.Username
+		}
+		page.Heading = "Server watchlist"
+	} else {
+		watchlist, err = bot.FetchWatchlist(srv.db, &bot.Viewer{}, share.UserID, true)
+		if err != nil {
+			return nil, err
+		}
+
+		username, err := bot.FetchUsername(srv.db, share.UserID)
+		if err != nil {
+			return nil, err
+		}
+		page.Heading = fmt.Sprintf("%s's watchlist", username)
+	}
+
+	// Stats cover the whole watchlist, the table only what matches the filters
+	page.Summary = bot.Summarize(watchlist.Entries)
+	watchlist.Sort(page.Sort)
+
+	for _, e := range watchlist.Entries {
+		if page.Category != "" && e.Category != page.Category {
+			continue
+		}
+		if (page.Status == STATUS_UNWATCHED && e.Done) || (page.Status == STATUS_DONE && !e.Done) {
+			continue
+		}
+		if page.Tag != "" && !e.HasTag(page.Tag) {
+			continue
+		}
+
+		loc, ok := locations[e.UserID]
+		if !ok {
+			user, err := bot.FetchUser(srv.db, e.UserID)
+			if err != nil {
+				return nil, err
+			}
+			loc = user.Location()
+			locations[e.UserID] = loc
+		}
+
+		page.Rows = append(page.Rows, &watchlistRow{
+			Entry: e,
+			Owner: usernames[e.UserID],
+			Added: e.Date.In(loc).Format("2006-01-02"),
+		})
+	}
+
+	return page, nil
+}
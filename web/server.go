@@ -0,0 +1,252 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/ttamre/watchlist/bot"
+)
+
+// Server serves the HTTP API and shared watchlist pages over the watchlist database
+type Server struct {
+	db         *sql.DB
+	adminToken string // grants access to every user's watchlist, empty to disable
+	mux        *http.ServeMux
+}
+
+/*
+Create a server and register its routes
+
+Params:
+
+	db:			ptr to sqlite3 database connection
+	adminToken:	token that can access every user's watchlist (empty to only allow personal tokens)
+
+Returns:
+
+	*Server:	ptr to the server, ready to be used as an http.Handler
+*/
+func NewServer(db *sql.DB, adminToken string) *Server {
+	srv := &Server{db: db, adminToken: adminToken, mux: http.NewServeMux()}
+	srv.registerAPI()
+	srv.registerPages()
+	return srv
+}
+
+// Start serving on addr, blocks until the server fails
+func ListenAndServe(addr string, db *sql.DB, adminToken string) error {
+	slog.Info("server.ListenAndServe", "addr", addr)
+	return http.ListenAndServe(addr, NewServer(db, adminToken))
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// An API handler works on one user's watchlist and returns an error instead of writing it
+type apiHandler func(w http.ResponseWriter, r *http.Request, userID string) error
+
+// Wrap an API handler with authorization and error responses
+func (srv *Server) api(handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.PathValue("userID")
+
+		actor, err := srv.authorize(r, userID)
+		if err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), actorKey{}, actor))
+			err = handler(w, r, userID)
+		}
+
+		if err != nil {
+			status := statusFor(err)
+			if status == http.StatusInternalServerError {
+				slog.Error("server.api", "method", r.Method, "path", r.URL.Path, "msg", err)
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+		}
+	}
+}
+
+/*
+Check that a request may access a user's watchlist
+
+Requests authenticate with "Authorization: Bearer <token>", where the token is
+either the admin token or the user's personal token (./watchlist token), other users'
+personal tokens can only read the watchlist. Whether they can see it is up to its privacy
+setting, which is checked when it's fetched
+
+Params:
+
+	r:		incoming request
+	userID:	owner of the watchlist being accessed
+
+Returns:
+
+	*bot.Actor:	who is making the request, for the audit log
+	error:		InvalidTokenError, forbiddenError or a database error
+*/
+func (srv *Server) authorize(r *http.Request, userID string) (*bot.Actor, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, &bot.InvalidTokenError{}
+	}
+
+	if srv.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(srv.adminToken)) == 1 {
+		return &bot.Actor{Source: bot.SOURCE_API}, nil
+	}
+
+	user, err := bot.FetchUserByToken(srv.db, token)
+	if err != nil {
+		return nil, err
+	}
+	actor := &bot.Actor{UserID: user.UserID, Source: bot.SOURCE_API}
+	if user.UserID == userID {
+		return actor, nil
+	}
+
+	if r.Method != http.MethodGet {
+		return nil, &forbiddenError{user.UserID, userID}
+	}
+	return actor, nil
+}
+
+// Context key for the actor of an authorized request
+type actorKey struct{}
+
+// Who is making an authorized API request
+func actorOf(r *http.Request) *bot.Actor {
+	return r.Context().Value(actorKey{}).(*bot.Actor)
+}
+
+// Who is reading through an authorized API request, requests with the admin token have no user
+func viewerOf(r *http.Request) *bot.Viewer {
+	actor := actorOf(r)
+	return &bot.Viewer{UserID: actor.UserID, Admin: actor.UserID == ""}
+}
+
+// HTTP status code for an error returned by a handler, typed errors from bot/errors.go are the client's
+func statusFor(err error) int {
+	var (
+		notFound      *bot.EntryNotFoundError
+		empty         *bot.EmptyWatchlistError
+		noMatches     *bot.NoMatchingEntriesError
+		noReminder    *bot.ReminderNotFoundError
+		noParty       *bot.PartyNotFoundError
+		noShare       *bot.ShareNotFoundError
+		noMetadata    *bot.MetadataNotFoundError
+		noLink        *bot.LinkNotFoundError
+		notStreaming  *bot.AvailabilityNotFoundError
+		nothingToUndo *bot.NothingToUndoError
+		notInTrash    *bot.NotInTrashError
+		noPendingAdd  *bot.PendingAddNotFoundError
+		ambiguous     *bot.AmbiguousEntryError
+		trashed       *bot.EntryInTrashError
+		partyClosed   *bot.PartyClosedError
+		badTitle      *bot.InvalidTitleError
+		badCat        *bot.InvalidCategoryError
+		badUser       *bot.InvalidUserIDError
+		badTime       *bot.InvalidTimestampError
+		badSort       *bot.InvalidSortByError
+		badWeighting  *bot.InvalidWeightingError
+		badPick       *bot.InvalidPickOptionError
+		badDate       *bot.InvalidDateError
+		badRepeat     *bot.InvalidRepeatError
+		badRSVP       *bot.InvalidRSVPError
+		badTimezone   *bot.InvalidTimezoneError
+		badPrivacy    *bot.InvalidPrivacyError
+		badYear       *bot.InvalidYearError
+		badRating     *bot.InvalidRatingError
+		badLink       *bot.InvalidLinkError
+		badLinkKind   *bot.InvalidLinkKindError
+		badService    *bot.InvalidServiceError
+		badRegion     *bot.InvalidRegionError
+		badOffer      *bot.InvalidOfferError
+		badNumber     *bot.InvalidNumberError
+		badPosition   *bot.InvalidPositionError
+		notEnough     *bot.NotEnoughArgumentsError
+		notInGuild    *bot.NotInGuildError
+		badRequest    *badRequestError
+		badToken      *bot.InvalidTokenError
+		forbidden     *forbiddenError
+		private       *bot.PrivateWatchlistError
+		notHost       *bot.NotPartyHostError
+		notAdmin      *bot.NotGuildAdminError
+		cannotDM      *bot.CannotDMError
+		noProvider    *bot.NoAvailabilityProviderError
+		sqliteErr     sqlite3.Error
+	)
+
+	switch {
+	case errors.As(err, &notFound), errors.As(err, &empty), errors.As(err, &noMatches), errors.As(err, &noReminder),
+		errors.As(err, &noParty), errors.As(err, &noShare), errors.As(err, &noMetadata), errors.As(err, &noLink),
+		errors.As(err, &notStreaming), errors.As(err, &nothingToUndo), errors.As(err, &notInTrash), errors.As(err, &noPendingAdd):
+		return http.StatusNotFound
+	case errors.As(err, &ambiguous), errors.As(err, &trashed), errors.As(err, &partyClosed):
+		return http.StatusConflict
+	case errors.As(err, &badTitle), errors.As(err, &badCat), errors.As(err, &badUser), errors.As(err, &badTime),
+		errors.As(err, &badSort), errors.As(err, &badWeighting), errors.As(err, &badPick), errors.As(err, &badDate),
+		errors.As(err, &badRepeat), errors.As(err, &badRSVP), errors.As(err, &badTimezone), errors.As(err, &badPrivacy),
+		errors.As(err, &badYear), errors.As(err, &badRating), errors.As(err, &badLink), errors.As(err, &badLinkKind),
+		errors.As(err, &badService), errors.As(err, &badRegion), errors.As(err, &badOffer), errors.As(err, &badNumber),
+		errors.As(err, &badPosition), errors.As(err, &notEnough), errors.As(err, &notInGuild), errors.As(err, &badRequest):
+		return http.StatusBadRequest
+	case errors.As(err, &badToken):
+		return http.StatusUnauthorized
+	case errors.As(err, &forbidden), errors.As(err, &private), errors.As(err, &notHost), errors.As(err, &notAdmin),
+		errors.As(err, &cannotDM):
+		return http.StatusForbidden
+	case errors.As(err, &noProvider):
+		// Streaming lookups are turned off on this server, not something the client did wrong
+		return http.StatusNotImplemented
+	case errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint:
+		// Composite primary key, the entry already exists
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Write a value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("server.writeJSON", "msg", err)
+	}
+}
+
+// Decode a JSON request body, any failure is the client's fault
+func readJSON(r *http.Request, value any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return &badRequestError{err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+/*
+watchlist - a watchlist manager discord bot
+Copyright (C) 2024 Tem Tamre
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+
+	"github.com/ttamre/watchlist/bot"
+)
+
+const TEST_ADMIN_TOKEN = "admin-secret"
+
+// Open an empty, migrated database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := bot.OpenDatabase(filepath.Join(t.TempDir(), "watchlist.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := bot.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Create a personal API token for a user
+func newTestToken(t *testing.T, db *sql.DB, userID string) string {
+	t.Helper()
+
+	user, err := bot.FetchUser(db, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := user.NewAPIToken(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestStatusFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"not found", &bot.EntryNotFoundError{}, http.StatusNotFound},
+		{"not in trash", &bot.NotInTrashError{}, http.StatusNotFound},
+		{"nothing to undo", &bot.NothingToUndoError{}, http.StatusNotFound},
+		{"empty watchlist", &bot.EmptyWatchlistError{}, http.StatusNotFound},
+		{"ambiguous", &bot.AmbiguousEntryError{}, http.StatusConflict},
+		{"in trash", &bot.EntryInTrashError{}, http.StatusConflict},
+		{"party closed", &bot.PartyClosedError{}, http.StatusConflict},
+		{"invalid title", &bot.InvalidTitleError{}, http.StatusBadRequest},
+		{"invalid category", &bot.InvalidCategoryError{}, http.StatusBadRequest},
+		{"invalid user", &bot.InvalidUserIDError{}, http.StatusBadRequest},
+		{"invalid timestamp", &bot.InvalidTimestampError{}, http.StatusBadRequest},
+		{"invalid sort", &bot.InvalidSortByError{}, http.StatusBadRequest},
+		{"invalid link", &bot.InvalidLinkError{}, http.StatusBadRequest},
+		{"invalid link kind", new(bot.InvalidLinkKindError), http.StatusBadRequest},
+		{"invalid pick option", &bot.InvalidPickOptionError{}, http.StatusBadRequest},
+		{"invalid privacy", &bot.InvalidPrivacyError{}, http.StatusBadRequest},
+		{"invalid rating", &bot.InvalidRatingError{}, http.StatusBadRequest},
+		{"invalid year", &bot.InvalidYearError{}, http.StatusBadRequest},
+		{"invalid position", &bot.InvalidPositionError{}, http.StatusBadRequest},
+		{"bad request", &badRequestError{"unexpected EOF"}, http.StatusBadRequest},
+		{"invalid token", &bot.InvalidTokenError{}, http.StatusUnauthorized},
+		{"forbidden", &forbiddenError{"1", "2"}, http.StatusForbidden},
+		{"private watchlist", &bot.PrivateWatchlistError{}, http.StatusForbidden},
+		{"not the host", &bot.NotPartyHostError{}, http.StatusForbidden},
+		{"streaming lookups off", &bot.NoAvailabilityProviderError{}, http.StatusNotImplemented},
+		{"constraint", sqlite3.Error{Code: sqlite3.ErrConstraint}, http.StatusConflict},
+		{"wrapped", fmt.Errorf("fetch: %w", &bot.EntryNotFoundError{}), http.StatusNotFound},
+		{"busy database", sqlite3.Error{Code: sqlite3.ErrBusy}, http.StatusInternalServerError},
+		{"unknown", errors.New("disk on fire"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusFor(tt.err); got != tt.want {
+				t.Errorf("statusFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	db := newTestDB(t)
+	srv := NewServer(db, TEST_ADMIN_TOKEN)
+	ownToken := newTestToken(t, db, "1")
+	otherToken := newTestToken(t, db, "2")
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"no header", "", http.StatusUnauthorized},
+		{"not bearer", "Basic " + ownToken, http.StatusUnauthorized},
+		{"unknown token", "Bearer nope", http.StatusUnauthorized},
+		{"admin token", "Bearer " + TEST_ADMIN_TOKEN, http.StatusOK},
+		{"own token", "Bearer " + ownToken, http.StatusOK},
+		{"other user's token", "Bearer " + otherToken, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/users/1/entries", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			srv.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAdminDisabled(t *testing.T) {
+	// An empty admin token must not match an empty bearer token or grant anything
+	srv := NewServer(newTestDB(t), "")
+
+	r := httptest.NewRequest(http.MethodGet, "/api/users/1/entries", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+
+	srv.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAddEntryDuplicate(t *testing.T) {
+	db := newTestDB(t)
+	srv := NewServer(db, TEST_ADMIN_TOKEN)
+
+	add := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/users/1/entries", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+TEST_ADMIN_TOKEN)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := add(`{"title": "Dune", "category": "movie", "year": 2021}`); w.Code != http.StatusCreated {
+		t.Fatalf("first add: status %d (body %s)", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"exact", `{"title": "Dune", "category": "movie", "year": 2021}`, http.StatusConflict},
+		{"unknown year", `{"title": "dune", "category": "show"}`, http.StatusConflict},
+		{"exact kept", `{"title": "Dune", "category": "movie", "year": 2021, "keep_both": true}`, http.StatusConflict},
+		{"other release", `{"title": "Dune", "category": "movie", "year": 1984}`, http.StatusCreated},
+		{"kept", `{"title": "Dune", "category": "show", "keep_both": true}`, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := add(tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body)
+			}
+			if tt.want != http.StatusConflict || strings.Contains(tt.body, "keep_both") {
+				return
+			}
+
+			// Conflicts come with the entry that's already there
+			var body struct {
+				Error    string     `json:"error"`
+				Existing *bot.Entry `json:"existing"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Existing == nil || body.Existing.Title != "Dune" || body.Existing.Year != 2021 || body.Error == "" {
+				t.Errorf("got %+v, want the error and Dune (2021)", body)
+			}
+		})
+	}
+}